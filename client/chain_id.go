@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+	cmtservice "github.com/cosmos/cosmos-sdk/client/grpc/cmtservice"
+)
+
+// verifyChainID compares the network reported by the node with Config.ChainID. Nothing is checked
+// when no chain id is configured.
+func (cc *ChainClient) verifyChainID() error {
+	if cc.Config.ChainID == "" {
+		return nil
+	}
+
+	ctx := context.Background()
+
+	// A gRPC only client has no RPC, the node info is then read from the app gRPC server
+	if cc.RPCClient == nil {
+		res, err := cmtservice.NewServiceClient(cc).GetNodeInfo(ctx, &cmtservice.GetNodeInfoRequest{})
+		if err != nil {
+			return fmt.Errorf("failed to get node info to verify the chain id: %w", err)
+		}
+		if res.DefaultNodeInfo == nil {
+			return fmt.Errorf("failed to verify the chain id: %s returned no node info", cc.Config.GRPCAddr)
+		}
+		if res.DefaultNodeInfo.Network != cc.Config.ChainID {
+			return chainIDMismatch(cc.Config.GRPCAddr, res.DefaultNodeInfo.Network, cc.Config.ChainID)
+		}
+		return nil
+	}
+
+	var status *coretypes.ResultStatus
+	err := cc.Retry(ctx, func(ctx context.Context) (err error) {
+		status, err = cc.RPCClient.Status(ctx)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get node status to verify the chain id: %w", err)
+	}
+
+	if status.NodeInfo.Network != cc.Config.ChainID {
+		addr := strings.Join(cc.Config.rpcPoolAddrs(), ", ")
+		if cc.Config.Transport == TransportREST {
+			addr = cc.Config.APIAddr
+		}
+		return chainIDMismatch(addr, status.NodeInfo.Network, cc.Config.ChainID)
+	}
+
+	return nil
+}
+
+// VerifyBlock checks that the block belongs to Config.ChainID when StrictChainID is enabled, and
+// verifies it with the light client when one is configured
+func (cc *ChainClient) VerifyBlock(ctx context.Context, block *cmttypes.Block) error {
+	if block == nil {
+		return nil
+	}
+
+	if cc.Config.StrictChainID && cc.Config.ChainID != "" && block.Header.ChainID != cc.Config.ChainID {
+		return fmt.Errorf("%w: block %d belongs to %q, expected %q",
+			ErrChainIDMismatch, block.Header.Height, block.Header.ChainID, cc.Config.ChainID)
+	}
+
+	if cc.LightClient == nil {
+		return nil
+	}
+
+	// The block hash is the header hash, the data and commit are bound to it by their hashes
+	if err := block.ValidateBasic(); err != nil {
+		return fmt.Errorf("%w: block %d: %w", ErrLightClientVerification, block.Header.Height, err)
+	}
+
+	return cc.verifyLightBlock(ctx, block.Header.Height, block.Hash())
+}
+
+func chainIDMismatch(addr, network, chainID string) error {
+	return fmt.Errorf("%w: node %s serves %q, expected %q, check the configured addresses",
+		ErrChainIDMismatch, addr, network, chainID)
+}
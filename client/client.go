@@ -4,47 +4,203 @@ package client
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
+	probeCodecTypes "github.com/RiemaLabs/probe/client/codec/types"
 	"github.com/RiemaLabs/probe/logger"
+	dbm "github.com/cometbft/cometbft-db"
+	"github.com/cometbft/cometbft/light"
 	rpcclient "github.com/cometbft/cometbft/rpc/client"
 	rpchttp "github.com/cometbft/cometbft/rpc/client/http"
 	libclient "github.com/cometbft/cometbft/rpc/jsonrpc/client"
+	"google.golang.org/grpc"
 )
 
 type ChainClient struct {
-	Config    *ChainClientConfig
-	RPCClient rpcclient.Client
-	Codec     Codec
+	Config      *ChainClientConfig
+	RPCClient   rpcclient.Client
+	GRPCConn    *grpc.ClientConn
+	LightClient *light.Client
+	Codec       Codec
+
+	// CodecSchedule holds the codecs of the heights decoded differently from Codec, see CodecForHeader
+	CodecSchedule []ScheduledCodec
+
+	// ReflectionReport is set when the codec was completed from the reflection services of the chain
+	ReflectionReport *ReflectionReport
+
+	lightMtx sync.Mutex
+	// lightDB is the trusted store of the light client, closed by Stop
+	lightDB dbm.DB
 }
 
 func NewChainClient(ccc *ChainClientConfig) (*ChainClient, error) {
 
-	codec, err := MakeCodec(ccc.Modules, ccc.CustomMsgTypeRegistry)
+	if err := ccc.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	// Configs built without CustomTypeRegistry still get the taproot public keys of the chain, like LoadConfig
+	customTypeRegistry := ccc.CustomTypeRegistry
+	if customTypeRegistry == nil {
+		customTypeRegistry = DefaultCustomTypeRegistry
+	}
 
+	modules, customTypes, err := ccc.codecTypes(ccc.Modules, customTypeRegistry, ccc.CustomMsgTypeRegistry)
+	if err != nil {
+		return nil, err
+	}
+
+	codec, err := MakeCodecWithPolicy(modules, customTypes, probeCodecTypes.ConflictPolicy(ccc.ConflictPolicy))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := codec.RegisterDescriptorSets(ccc.DescriptorSets...); err != nil {
+		return nil, err
+	}
+
+	schedule, err := makeCodecSchedule(ccc)
 	if err != nil {
 		return nil, err
 	}
 
 	cc := &ChainClient{
-		Config: ccc,
-		Codec:  codec,
+		Config:        ccc,
+		Codec:         codec,
+		CodecSchedule: schedule,
 	}
 
 	if err := cc.Init(); err != nil {
 		return nil, err
 	}
 
+	if ccc.Reflection {
+		report, err := cc.RegisterReflectedTypes(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		cc.ReflectionReport = report
+	}
+
 	return cc, nil
 }
 
+// Init connects the RPC and gRPC clients, checks that the node serves Config.ChainID and sets up
+// the light client when one is configured
 func (cc *ChainClient) Init() error {
+	if err := cc.connect(); err != nil {
+		return err
+	}
+
+	if err := cc.verifyChainID(); err != nil {
+		if cc.RPCClient != nil && cc.RPCClient.IsRunning() {
+			_ = cc.RPCClient.Stop()
+		}
+		return err
+	}
+
+	return cc.initLightClient()
+}
+
+// Stop stops the RPC client and closes the gRPC connection and the trusted store of the light client
+func (cc *ChainClient) Stop() error {
+	var errs []error
+	if cc.RPCClient != nil && cc.RPCClient.IsRunning() {
+		errs = append(errs, cc.RPCClient.Stop())
+	}
+	if cc.GRPCConn != nil {
+		errs = append(errs, cc.GRPCConn.Close())
+	}
+
+	cc.lightMtx.Lock()
+	defer cc.lightMtx.Unlock()
+	if cc.lightDB != nil {
+		errs = append(errs, cc.lightDB.Close())
+		cc.lightDB = nil
+	}
+
+	return errors.Join(errs...)
+}
+
+// RequireRPC returns an error matching errors.ErrUnsupported when the client has no CometBFT RPC,
+// i.e. a gRPC only client without rpc-addr. what names the call that needs the RPC.
+func (cc *ChainClient) RequireRPC(what string) error {
+	if cc.RPCClient != nil {
+		return nil
+	}
+	return fmt.Errorf("%s is not available without an rpc address: %w", what, errors.ErrUnsupported)
+}
+
+func (cc *ChainClient) connect() error {
 
-	timeout, _ := time.ParseDuration(cc.Config.Timeout)
-	rpcClient, err := NewRPCClient(cc.Config.RPCAddr, timeout, cc.Config.Debug)
+	timeout, err := time.ParseDuration(cc.Config.Timeout)
+	if err != nil {
+		return fmt.Errorf("invalid timeout %q: %w", cc.Config.Timeout, err)
+	}
+
+	if cc.Config.Transport == TransportGRPC || cc.Config.Transport == TransportBoth {
+		if cc.Config.GRPCAddr == "" {
+			return fmt.Errorf("transport %s requires a grpc address", cc.Config.Transport)
+		}
+
+		grpcConn, err := NewGRPCConn(cc.Config.GRPCAddr, cc.Codec)
+		if err != nil {
+			return err
+		}
+
+		cc.GRPCConn = grpcConn
+	}
+
+	// The REST gateway replaces the CometBFT RPC entirely
+	if cc.Config.Transport == TransportREST {
+		if cc.Config.APIAddr == "" {
+			return fmt.Errorf("transport %s requires an api address", cc.Config.Transport)
+		}
+
+		restClient, err := NewRESTClient(cc.Config.APIAddr, timeout, cc.Config.Debug, cc.Codec)
+		if err != nil {
+			return err
+		}
+
+		cc.RPCClient = restClient
+		return nil
+	}
+
+	addrs := cc.Config.rpcPoolAddrs()
+	if len(addrs) == 0 {
+		// Block data is always fetched from the RPC, but a gRPC only client can still run module queries
+		if cc.Config.Transport == TransportGRPC {
+			return nil
+		}
+		return fmt.Errorf("no rpc address configured")
+	}
+
+	// Pool mode is used when several RPC addresses are configured
+	if len(addrs) > 1 {
+		interval, _ := time.ParseDuration(cc.Config.HealthCheckInterval)
+		pool, err := NewRPCPool(addrs, cc.Config.ChainID, timeout, cc.Config.Debug, interval)
+		if err != nil {
+			return err
+		}
+
+		if err := pool.Start(); err != nil {
+			return err
+		}
+
+		cc.RPCClient = pool
+		return nil
+	}
+
+	rpcClient, err := NewRPCClient(addrs[0], timeout, cc.Config.Debug)
 	if err != nil {
 		return err
 	}
@@ -70,25 +226,30 @@ func (lrt *loggingRoundTripper) RoundTrip(req *http.Request) (*http.Response, er
 }
 
 func NewRPCClient(addr string, timeout time.Duration, debug bool) (*rpchttp.HTTP, error) {
-	httpClient, err := libclient.DefaultHTTPClient(addr)
+	rpcClient, err := newRPCHTTPClient(addr, timeout, debug)
 	if err != nil {
 		return nil, err
 	}
-	httpClient.Timeout = timeout
-	if debug {
-		httpClient.Transport = &loggingRoundTripper{httpClient.Transport}
-	}
-
-	addr = strings.TrimSuffix(addr, "/")
 
-	rpcClient, err := rpchttp.NewWithClient(addr, "/websocket", httpClient)
+	err = rpcClient.Start()
 	if err != nil {
 		return nil, err
 	}
+	return rpcClient, nil
+}
 
-	err = rpcClient.Start()
+// newRPCHTTPClient creates an RPC client without starting its websocket connection
+func newRPCHTTPClient(addr string, timeout time.Duration, debug bool) (*rpchttp.HTTP, error) {
+	httpClient, err := libclient.DefaultHTTPClient(addr)
 	if err != nil {
 		return nil, err
 	}
-	return rpcClient, nil
+	httpClient.Timeout = timeout
+	if debug {
+		httpClient.Transport = &loggingRoundTripper{httpClient.Transport}
+	}
+
+	addr = strings.TrimSuffix(addr, "/")
+
+	return rpchttp.NewWithClient(addr, "/websocket", httpClient)
 }
@@ -0,0 +1,43 @@
+package types
+
+// ConflictPolicy decides what happens when a concrete type is registered under a type URL that
+// already has a different one, e.g. when a fork redefines a message of an SDK module
+type ConflictPolicy string
+
+const (
+	// ConflictError fails the registration, it is the default
+	ConflictError ConflictPolicy = "error"
+	// ConflictKeepFirst keeps the type registered first and ignores the others
+	ConflictKeepFirst ConflictPolicy = "keep-first"
+	// ConflictOverrideWithCustom lets the types registered with RegisterCustomTypeURL replace the
+	// existing ones, other conflicts keep the type registered first
+	ConflictOverrideWithCustom ConflictPolicy = "override-with-custom"
+)
+
+// ConflictPolicies lists the supported conflict policies
+var ConflictPolicies = []ConflictPolicy{ConflictError, ConflictKeepFirst, ConflictOverrideWithCustom}
+
+// Conflict is a type URL registered with two different concrete types
+type Conflict struct {
+	TypeURL string
+	// Interface is the interface of the new type, the existing one may implement another interface
+	Interface string
+	// Existing is the type registered first and New the one registered after it
+	Existing string
+	New      string
+	// Winner is the type registered under TypeURL once the conflict is resolved
+	Winner string
+}
+
+// SetConflictPolicy sets the policy of the next registrations, an empty policy is ConflictError
+func (registry *ProbeInterfaceRegistry) SetConflictPolicy(policy ConflictPolicy) {
+	registry.mtx.Lock()
+	defer registry.mtx.Unlock()
+	registry.policy = policy
+}
+
+// Diagnostics lists the conflicts resolved by the conflict policy, in registration order
+func (registry *ProbeInterfaceRegistry) Diagnostics() []Conflict {
+	defer registry.rlock()()
+	return append([]Conflict(nil), registry.conflicts...)
+}
@@ -0,0 +1,351 @@
+package types
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/cosmos/gogoproto/jsonpb"
+	"github.com/cosmos/gogoproto/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// msgServiceOption is the cosmos.msg.v1.service option that marks the Msg services of a module
+const (
+	msgServiceOption       protoreflect.FullName = "cosmos.msg.v1.service"
+	msgServiceOptionNumber protowire.Number      = 11110000
+)
+
+// DynamicMsg is a message known only by its descriptor, loaded from a FileDescriptorSet.
+//
+// It implements the gogoproto Message interface, so it can be packed in an Any and decoded in a tx
+// like compiled messages, and it is rendered as proto-JSON by the codec.
+type DynamicMsg struct {
+	*dynamicpb.Message
+
+	resolver *dynamicResolver
+}
+
+var dynamicMsgType = reflect.TypeOf(&DynamicMsg{})
+
+func (registry *ProbeInterfaceRegistry) newDynamicMsg(desc protoreflect.MessageDescriptor) *DynamicMsg {
+	return &DynamicMsg{Message: dynamicpb.NewMessage(desc), resolver: &dynamicResolver{registry: registry}}
+}
+
+func (m *DynamicMsg) Reset() {
+	m.Message = dynamicpb.NewMessage(m.Descriptor())
+}
+
+func (m *DynamicMsg) String() string {
+	return protojson.MarshalOptions{}.Format(m.Message)
+}
+
+func (*DynamicMsg) ProtoMessage() {}
+
+// XXX_MessageName is used by gogoproto to build the type URL of the message
+func (m *DynamicMsg) XXX_MessageName() string {
+	return string(m.Descriptor().FullName())
+}
+
+func (m *DynamicMsg) Marshal() ([]byte, error) {
+	return protov2.MarshalOptions{Deterministic: true}.Marshal(m.Message)
+}
+
+func (m *DynamicMsg) Unmarshal(bz []byte) error {
+	m.Reset()
+	return protov2.UnmarshalOptions{Resolver: m.resolver}.Unmarshal(bz, m.Message)
+}
+
+// MarshalJSONPB renders the message as proto-JSON when it is marshaled by the codec
+func (m *DynamicMsg) MarshalJSONPB(*jsonpb.Marshaler) ([]byte, error) {
+	return protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true, Resolver: m.resolver}.Marshal(m.Message)
+}
+
+func (m *DynamicMsg) UnmarshalJSONPB(_ *jsonpb.Unmarshaler, bz []byte) error {
+	m.Reset()
+	return protojson.UnmarshalOptions{Resolver: m.resolver}.Unmarshal(bz, m.Message)
+}
+
+// LoadFileDescriptorSet reads a binary FileDescriptorSet, such as a buf image or a .binpb file
+// produced by protoc --descriptor_set_out --include_imports
+func LoadFileDescriptorSet(path string) (*descriptorpb.FileDescriptorSet, error) {
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// A buf image is wire compatible with a FileDescriptorSet, the buf specific fields are skipped
+	fds := &descriptorpb.FileDescriptorSet{}
+	if err := (protov2.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(bz, fds); err != nil {
+		return nil, fmt.Errorf("failed to parse file descriptor set %s: %w", path, err)
+	}
+
+	return fds, nil
+}
+
+// RegisterFileDescriptorSet registers a DynamicMsg implementation of sdk.Msg for every Msg service
+// request of the set, i.e. the inputs of services annotated with cosmos.msg.v1.service or named Msg.
+// Messages with a compiled Go type keep it. Imports missing from the set are looked up in the
+// compiled descriptors. It returns the type URLs registered.
+func (registry *ProbeInterfaceRegistry) RegisterFileDescriptorSet(msgIface interface{}, fds *descriptorpb.FileDescriptorSet) ([]string, error) {
+	files, err := buildFiles(fds)
+	if err != nil {
+		return nil, err
+	}
+
+	ityp := reflect.TypeOf(msgIface).Elem()
+
+	if err := registry.lock(); err != nil {
+		return nil, err
+	}
+	defer registry.mtx.Unlock()
+
+	var typeURLs []string
+	var rangeErr error
+	files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		services := fd.Services()
+		for i := 0; i < services.Len(); i++ {
+			service := services.Get(i)
+			if !isMsgService(service) {
+				continue
+			}
+
+			methods := service.Methods()
+			for j := 0; j < methods.Len(); j++ {
+				desc := methods.Get(j).Input()
+				typeURL := "/" + string(desc.FullName())
+				if registry.typeURLIsRegistered(typeURL) {
+					continue
+				}
+
+				if err := registry.registerDynamic(ityp, typeURL, desc); err != nil {
+					rangeErr = err
+					return false
+				}
+				typeURLs = append(typeURLs, typeURL)
+			}
+		}
+		return true
+	})
+	if rangeErr != nil {
+		return nil, rangeErr
+	}
+
+	// Every message of the set can be resolved, so that nested Any fields are rendered
+	registry.dynamicFiles = append(registry.dynamicFiles, files)
+
+	return typeURLs, nil
+}
+
+// HasDynamicTypes reports whether messages have been loaded from descriptor sets
+func (registry *ProbeInterfaceRegistry) HasDynamicTypes() bool {
+	defer registry.rlock()()
+	return len(registry.dynamicTypes) > 0
+}
+
+// RegisterDynamicImplementation registers a DynamicMsg implementation of the interface registered
+// under ifaceName, e.g. cosmos.base.v1beta1.Msg, for the type URL. The message descriptor is looked
+// up in the registered descriptor sets, then in the compiled descriptors. It fails when DynamicMsg
+// lacks the methods of the interface.
+func (registry *ProbeInterfaceRegistry) RegisterDynamicImplementation(ifaceName string, typeURL string) error {
+	if err := registry.lock(); err != nil {
+		return err
+	}
+	defer registry.mtx.Unlock()
+
+	typ, found := registry.interfaceNames[ifaceName]
+	if !found {
+		return fmt.Errorf("interface %s is not registered", ifaceName)
+	}
+
+	name := protoreflect.FullName(typeURL[strings.LastIndexByte(typeURL, '/')+1:])
+	msgType, err := registry.findMessageByName(name)
+	if err != nil {
+		if d, hybridErr := proto.HybridResolver.FindDescriptorByName(name); hybridErr == nil {
+			if md, ok := d.(protoreflect.MessageDescriptor); ok {
+				return registry.registerDynamic(typ.Elem(), typeURL, md)
+			}
+		}
+		return fmt.Errorf("no descriptor for type URL %s: %w", typeURL, err)
+	}
+
+	return registry.registerDynamic(typ.Elem(), typeURL, msgType.Descriptor())
+}
+
+// registerDynamic registers desc as the implementation of ityp under typeURL. The caller holds the write lock.
+func (registry *ProbeInterfaceRegistry) registerDynamic(ityp reflect.Type, typeURL string, desc protoreflect.MessageDescriptor) error {
+	if !dynamicMsgType.AssignableTo(ityp) {
+		return fmt.Errorf("dynamic messages don't implement interface %+v", ityp)
+	}
+
+	if found, ok := registry.dynamicTypes[typeURL]; ok && found.FullName() != desc.FullName() {
+		return fmt.Errorf("type URL %s is already registered for %s", typeURL, found.FullName())
+	}
+
+	registry.dynamicTypes[typeURL] = desc
+	registry.dynamicInterfaces[typeURL] = ityp
+	registry.implInterfaces[dynamicMsgType] = ityp
+
+	return nil
+}
+
+// buildFiles registers the files of the set in dependency order
+func buildFiles(fds *descriptorpb.FileDescriptorSet) (*protoregistry.Files, error) {
+	byName := make(map[string]*descriptorpb.FileDescriptorProto, len(fds.File))
+	for _, fd := range fds.File {
+		byName[fd.GetName()] = fd
+	}
+
+	files := &protoregistry.Files{}
+	resolver := &fallbackResolver{files: files, fallback: proto.HybridResolver}
+
+	var register func(name string, path []string) error
+	register = func(name string, path []string) error {
+		if _, err := files.FindFileByPath(name); err == nil {
+			return nil
+		}
+
+		fdp, ok := byName[name]
+		if !ok {
+			// Imports missing from the set must be compiled in
+			if _, err := proto.HybridResolver.FindFileByPath(name); err != nil {
+				return fmt.Errorf("import %s is neither in the descriptor set nor compiled in", name)
+			}
+			return nil
+		}
+
+		for _, p := range path {
+			if p == name {
+				return fmt.Errorf("import cycle through %s", name)
+			}
+		}
+
+		for _, dep := range fdp.GetDependency() {
+			if err := register(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+
+		fd, err := protodesc.NewFile(fdp, resolver)
+		if err != nil {
+			return fmt.Errorf("invalid descriptor %s: %w", name, err)
+		}
+
+		return files.RegisterFile(fd)
+	}
+
+	for _, fd := range fds.File {
+		if err := register(fd.GetName(), nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+func isMsgService(service protoreflect.ServiceDescriptor) bool {
+	if service.Name() == "Msg" {
+		return true
+	}
+
+	found := false
+	options := service.Options().ProtoReflect()
+	options.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.FullName() == msgServiceOption && fd.Kind() == protoreflect.BoolKind && v.Bool() {
+			found = true
+			return false
+		}
+		return true
+	})
+	if found {
+		return true
+	}
+
+	// The option stays an unknown field when its extension is not compiled in
+	unknown := options.GetUnknown()
+	for len(unknown) > 0 {
+		num, typ, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			return false
+		}
+		unknown = unknown[n:]
+
+		if num == msgServiceOptionNumber && typ == protowire.VarintType {
+			v, m := protowire.ConsumeVarint(unknown)
+			return m > 0 && v != 0
+		}
+
+		m := protowire.ConsumeFieldValue(num, typ, unknown)
+		if m < 0 {
+			return false
+		}
+		unknown = unknown[m:]
+	}
+	return false
+}
+
+// fallbackResolver resolves the files being built first and then the compiled descriptors
+type fallbackResolver struct {
+	files    *protoregistry.Files
+	fallback protodesc.Resolver
+}
+
+func (r *fallbackResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if fd, err := r.files.FindFileByPath(path); err == nil {
+		return fd, nil
+	}
+	return r.fallback.FindFileByPath(path)
+}
+
+func (r *fallbackResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if d, err := r.files.FindDescriptorByName(name); err == nil {
+		return d, nil
+	}
+	return r.fallback.FindDescriptorByName(name)
+}
+
+// dynamicResolver resolves the message types of the registered descriptor sets, then the compiled ones.
+// It lets the messages nested in the Any fields of a DynamicMsg be decoded and rendered.
+type dynamicResolver struct {
+	registry *ProbeInterfaceRegistry
+}
+
+func (r *dynamicResolver) FindMessageByName(name protoreflect.FullName) (protoreflect.MessageType, error) {
+	defer r.registry.rlock()()
+	return r.registry.findMessageByName(name)
+}
+
+func (registry *ProbeInterfaceRegistry) findMessageByName(name protoreflect.FullName) (protoreflect.MessageType, error) {
+	for _, files := range registry.dynamicFiles {
+		if d, err := files.FindDescriptorByName(name); err == nil {
+			if md, ok := d.(protoreflect.MessageDescriptor); ok {
+				return dynamicpb.NewMessageType(md), nil
+			}
+		}
+	}
+	return protoregistry.GlobalTypes.FindMessageByName(name)
+}
+
+func (r *dynamicResolver) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	name := url
+	if i := strings.LastIndexByte(url, '/'); i >= 0 {
+		name = url[i+1:]
+	}
+	return r.FindMessageByName(protoreflect.FullName(name))
+}
+
+func (r *dynamicResolver) FindExtensionByName(field protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	return protoregistry.GlobalTypes.FindExtensionByName(field)
+}
+
+func (r *dynamicResolver) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	return protoregistry.GlobalTypes.FindExtensionByNumber(message, field)
+}
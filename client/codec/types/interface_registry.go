@@ -2,25 +2,56 @@
 package types
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+	"sync"
+	"sync/atomic"
 
+	"cosmossdk.io/x/tx/signing"
+	addresscodec "github.com/cosmos/cosmos-sdk/codec/address"
 	cosmosCodecTypes "github.com/cosmos/cosmos-sdk/codec/types"
+	sdkTypes "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/gogoproto/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
 )
 
+// ProbeInterfaceRegistry implements the SDK InterfaceRegistry. It also resolves the messages
+// loaded from descriptor sets, see RegisterFileDescriptorSet.
+//
+// It is safe for concurrent use. Registrations take a write lock and lookups a read lock, Freeze
+// ends the registrations so that lookups no longer lock.
 type ProbeInterfaceRegistry struct {
+	// The SDK registry provides the signing context, its file resolver is the probe registry
 	cosmosCodecTypes.InterfaceRegistry
 
+	mtx    sync.RWMutex
+	frozen atomic.Bool
+
 	interfaceNames map[string]reflect.Type
 	interfaceImpls map[reflect.Type]interfaceMap
 	implInterfaces map[reflect.Type]reflect.Type
 	typeURLMap     map[string]reflect.Type
+
+	policy    ConflictPolicy
+	conflicts []Conflict
+
+	// Registrations made through the SDK interface record their errors
+	errMtx sync.Mutex
+	errs   []error
+
+	// Messages loaded from descriptor sets, they all share the DynamicMsg Go type
+	dynamicTypes      map[string]protoreflect.MessageDescriptor
+	dynamicInterfaces map[string]reflect.Type
+	dynamicFiles      []*protoregistry.Files
 }
 
 type interfaceMap = map[string]reflect.Type
 
-// NewInterfaceRegistry returns a new InterfaceRegistry
+// NewInterfaceRegistry returns a new InterfaceRegistry. Like the app, the signing context decodes
+// account addresses as taproot addresses, and validator addresses with the bech32 prefix of the
+// SDK config at the time of the call.
 func NewInterfaceRegistry() (cosmosCodecTypes.InterfaceRegistry, *ProbeInterfaceRegistry) {
 
 	probeRegistry := &ProbeInterfaceRegistry{
@@ -28,19 +59,89 @@ func NewInterfaceRegistry() (cosmosCodecTypes.InterfaceRegistry, *ProbeInterface
 		interfaceImpls: map[reflect.Type]interfaceMap{},
 		implInterfaces: map[reflect.Type]reflect.Type{},
 		typeURLMap:     map[string]reflect.Type{},
+
+		dynamicTypes:      map[string]protoreflect.MessageDescriptor{},
+		dynamicInterfaces: map[string]reflect.Type{},
+	}
+
+	config := sdkTypes.GetConfig()
+	sdkRegistry, err := cosmosCodecTypes.NewInterfaceRegistryWithOptions(cosmosCodecTypes.InterfaceRegistryOptions{
+		ProtoFiles: probeRegistry,
+		SigningOptions: signing.Options{
+			TypeResolver:          &dynamicResolver{registry: probeRegistry},
+			AddressCodec:          addresscodec.NewTaprootCodec(&sdkTypes.BitcoinNetParams),
+			ValidatorAddressCodec: addresscodec.NewBech32Codec(config.GetBech32ValidatorAddrPrefix()),
+		},
+	})
+	if err != nil {
+		panic(err)
 	}
+	probeRegistry.InterfaceRegistry = sdkRegistry
 
 	return probeRegistry, probeRegistry
 }
 
+// Freeze ends the registrations, the registry is then read only and its lookups don't lock.
+// Registering after Freeze fails, see Err for the methods that don't return errors.
+func (registry *ProbeInterfaceRegistry) Freeze() {
+	registry.mtx.Lock()
+	defer registry.mtx.Unlock()
+	registry.frozen.Store(true)
+}
+
+// IsFrozen reports whether Freeze has been called
+func (registry *ProbeInterfaceRegistry) IsFrozen() bool {
+	return registry.frozen.Load()
+}
+
+func (registry *ProbeInterfaceRegistry) rlock() func() {
+	if registry.frozen.Load() {
+		return func() {}
+	}
+	registry.mtx.RLock()
+	return registry.mtx.RUnlock
+}
+
+// lock takes the write lock of a registration, which fails once the registry is frozen
+func (registry *ProbeInterfaceRegistry) lock() error {
+	registry.mtx.Lock()
+	if registry.frozen.Load() {
+		registry.mtx.Unlock()
+		return errFrozen
+	}
+	return nil
+}
+
+var errFrozen = fmt.Errorf("the interface registry is frozen")
+
+// RegisterInterface associates protoName with the interface iface and registers impls as its
+// implementations.
+//
+// The SDK modules register their types through this method, which cannot return an error. Failures
+// are recorded instead of panicking, they are returned by Err.
 func (registry *ProbeInterfaceRegistry) RegisterInterface(protoName string, iface interface{}, impls ...proto.Message) {
+	registry.recordErr(registry.TryRegisterInterface(protoName, iface, impls...))
+}
+
+// TryRegisterInterface is RegisterInterface returning its error
+func (registry *ProbeInterfaceRegistry) TryRegisterInterface(protoName string, iface interface{}, impls ...proto.Message) error {
 	typ := reflect.TypeOf(iface)
-	if typ.Elem().Kind() != reflect.Interface {
-		panic(fmt.Errorf("%T is not an interface type", iface))
+	if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Interface {
+		return fmt.Errorf("%T is not an interface type", iface)
 	}
 
+	if err := registry.lock(); err != nil {
+		return err
+	}
+	defer registry.mtx.Unlock()
+
 	registry.interfaceNames[protoName] = typ
-	registry.RegisterImplementations(iface, impls...)
+
+	var errs []error
+	for _, impl := range impls {
+		errs = append(errs, registry.registerImpl(iface, "/"+proto.MessageName(impl), impl, false))
+	}
+	return errors.Join(errs...)
 }
 
 // EnsureRegistered ensures there is a registered interface for the given concrete type.
@@ -51,6 +152,8 @@ func (registry *ProbeInterfaceRegistry) EnsureRegistered(impl interface{}) error
 		return fmt.Errorf("%T is not a pointer", impl)
 	}
 
+	defer registry.rlock()()
+
 	if _, found := registry.implInterfaces[reflect.TypeOf(impl)]; !found {
 		return fmt.Errorf("%T does not have a registered interface", impl)
 	}
@@ -61,35 +164,75 @@ func (registry *ProbeInterfaceRegistry) EnsureRegistered(impl interface{}) error
 // RegisterImplementations registers a concrete proto Message which implements
 // the given interface.
 //
-// This function PANICs if different concrete types are registered under the
-// same typeURL.
+// Conflicting type URLs are resolved with the conflict policy, failures are returned by Err.
 func (registry *ProbeInterfaceRegistry) RegisterImplementations(iface interface{}, impls ...proto.Message) {
+	registry.recordErr(registry.TryRegisterImplementations(iface, impls...))
+}
+
+// TryRegisterImplementations is RegisterImplementations returning its error
+func (registry *ProbeInterfaceRegistry) TryRegisterImplementations(iface interface{}, impls ...proto.Message) error {
+	if err := registry.lock(); err != nil {
+		return err
+	}
+	defer registry.mtx.Unlock()
+
+	var errs []error
 	for _, impl := range impls {
 		typeURL := "/" + proto.MessageName(impl)
-		registry.registerImpl(iface, typeURL, impl)
+		errs = append(errs, registry.registerImpl(iface, typeURL, impl, false))
 	}
+	return errors.Join(errs...)
 }
 
 // RegisterCustomTypeURL registers a concrete type which implements the given
 // interface under `typeURL`.
 //
-// This function PANICs if different concrete types are registered under the
-// same typeURL.
-func (registry *ProbeInterfaceRegistry) RegisterCustomTypeURL(iface interface{}, typeURL string, impl proto.Message) {
-	registry.registerImpl(iface, typeURL, impl)
+// With ConflictOverrideWithCustom, the type replaces the one already registered under typeURL.
+func (registry *ProbeInterfaceRegistry) RegisterCustomTypeURL(iface interface{}, typeURL string, impl proto.Message) error {
+	if err := registry.lock(); err != nil {
+		return err
+	}
+	defer registry.mtx.Unlock()
+
+	return registry.registerImpl(iface, typeURL, impl, true)
+}
+
+// RegisterCustomTypeURLForInterface is RegisterCustomTypeURL for the interface registered under
+// ifaceName, e.g. cosmos.crypto.PubKey
+func (registry *ProbeInterfaceRegistry) RegisterCustomTypeURLForInterface(ifaceName string, typeURL string, impl proto.Message) error {
+	if err := registry.lock(); err != nil {
+		return err
+	}
+	defer registry.mtx.Unlock()
+
+	typ, found := registry.interfaceNames[ifaceName]
+	if !found {
+		return fmt.Errorf("interface %s is not registered", ifaceName)
+	}
+
+	return registry.registerImpl(reflect.Zero(typ).Interface(), typeURL, impl, true)
 }
 
 func (registry *ProbeInterfaceRegistry) TypeURLIsRegistered(typeURL string) bool {
+	defer registry.rlock()()
+	return registry.typeURLIsRegistered(typeURL)
+}
+
+func (registry *ProbeInterfaceRegistry) typeURLIsRegistered(typeURL string) bool {
+	if _, found := registry.dynamicTypes[typeURL]; found {
+		return true
+	}
 	_, found := registry.typeURLMap[typeURL]
 	return found
 }
 
 // registerImpl registers a concrete type which implements the given
-// interface under `typeURL`.
+// interface under `typeURL`. The caller holds the write lock.
 //
-// This function PANICs if different concrete types are registered under the
-// same typeURL.
-func (registry *ProbeInterfaceRegistry) registerImpl(iface interface{}, typeURL string, impl proto.Message) {
+// A different concrete type already registered under the same typeURL is a conflict, resolved
+// with the conflict policy, even when it implements another interface: Resolve returns a single
+// type per typeURL. custom marks the types registered with RegisterCustomTypeURL.
+func (registry *ProbeInterfaceRegistry) registerImpl(iface interface{}, typeURL string, impl proto.Message, custom bool) error {
 	ityp := reflect.TypeOf(iface).Elem()
 	imap, found := registry.interfaceImpls[ityp]
 	if !found {
@@ -98,34 +241,87 @@ func (registry *ProbeInterfaceRegistry) registerImpl(iface interface{}, typeURL
 
 	implType := reflect.TypeOf(impl)
 	if !implType.AssignableTo(ityp) {
-		panic(fmt.Errorf("type %T doesn't actually implement interface %+v", impl, ityp))
+		return fmt.Errorf("type %T doesn't actually implement interface %+v", impl, ityp)
 	}
 
-	// Check if we already registered something under the given typeURL. It's
-	// okay to register the same concrete type again, but if we are registering
-	// a new concrete type under the same typeURL, then we throw an error (here,
-	// we panic).
-	foundImplType, found := imap[typeURL]
+	// Check if we already registered something under the given typeURL, for any interface. It's
+	// okay to register the same concrete type again, but registering a new
+	// concrete type under the same typeURL is a conflict.
+	foundImplType, found := registry.typeURLMap[typeURL]
 	if found && foundImplType != implType {
-		panic(
-			fmt.Errorf(
+		conflict := Conflict{
+			TypeURL:   typeURL,
+			Interface: registry.interfaceName(ityp),
+			Existing:  foundImplType.String(),
+			New:       implType.String(),
+			Winner:    foundImplType.String(),
+		}
+
+		switch {
+		case registry.policy == ConflictKeepFirst, registry.policy == ConflictOverrideWithCustom && !custom:
+			registry.conflicts = append(registry.conflicts, conflict)
+			return nil
+		case registry.policy == ConflictOverrideWithCustom:
+			conflict.Winner = implType.String()
+			registry.conflicts = append(registry.conflicts, conflict)
+		default:
+			return fmt.Errorf(
 				"concrete type %s has already been registered under typeURL %s, cannot register %s under same typeURL. "+
 					"This usually means that there are conflicting modules registering different concrete types "+
 					"for a same interface implementation",
 				foundImplType,
 				typeURL,
 				implType,
-			),
-		)
+			)
+		}
+
+		// The replaced type no longer implements any interface under typeURL
+		for _, otherImpls := range registry.interfaceImpls {
+			if otherImpls[typeURL] == foundImplType {
+				delete(otherImpls, typeURL)
+			}
+		}
 	}
 
 	imap[typeURL] = implType
 	registry.typeURLMap[typeURL] = implType
 	registry.implInterfaces[implType] = ityp
 	registry.interfaceImpls[ityp] = imap
+
+	return nil
+}
+
+// interfaceName returns the proto name ityp is registered under, or its Go name
+func (registry *ProbeInterfaceRegistry) interfaceName(ityp reflect.Type) string {
+	for name, typ := range registry.interfaceNames {
+		if typ.Elem() == ityp {
+			return name
+		}
+	}
+	return ityp.String()
+}
+
+func (registry *ProbeInterfaceRegistry) recordErr(err error) {
+	if err == nil {
+		return
+	}
+
+	registry.errMtx.Lock()
+	defer registry.errMtx.Unlock()
+	registry.errs = append(registry.errs, err)
+}
+
+// Err returns the failures of the registrations made through the methods of the SDK
+// InterfaceRegistry, which cannot return them, joined with errors.Join
+func (registry *ProbeInterfaceRegistry) Err() error {
+	registry.errMtx.Lock()
+	defer registry.errMtx.Unlock()
+	return errors.Join(registry.errs...)
 }
 
 func (registry *ProbeInterfaceRegistry) ListAllInterfaces() []string {
+	defer registry.rlock()()
+
 	interfaceNames := registry.interfaceNames
 	keys := make([]string, 0, len(interfaceNames))
 	for key := range interfaceNames {
@@ -135,6 +331,8 @@ func (registry *ProbeInterfaceRegistry) ListAllInterfaces() []string {
 }
 
 func (registry *ProbeInterfaceRegistry) ListImplementations(ifaceName string) []string {
+	defer registry.rlock()()
+
 	typ, ok := registry.interfaceNames[ifaceName]
 	if !ok {
 		return []string{}
@@ -149,10 +347,51 @@ func (registry *ProbeInterfaceRegistry) ListImplementations(ifaceName string) []
 	for key := range impls {
 		keys = append(keys, key)
 	}
+	for key, ityp := range registry.dynamicInterfaces {
+		if ityp == typ.Elem() {
+			keys = append(keys, key)
+		}
+	}
 	return keys
 }
 
 func (registry *ProbeInterfaceRegistry) UnpackAny(any *cosmosCodecTypes.Any, iface interface{}) error {
+	unpacker := &statefulUnpacker{
+		registry: registry,
+		maxDepth: cosmosCodecTypes.MaxUnpackAnyRecursionDepth,
+		maxCalls: &sharedCounter{count: cosmosCodecTypes.MaxUnpackAnySubCalls},
+	}
+	return unpacker.UnpackAny(any, iface)
+}
+
+// sharedCounter counts the calls left to the unpackers of a same UnpackAny
+type sharedCounter struct {
+	count int
+}
+
+// statefulUnpacker bounds the recursion depth and the number of calls of UnpackAny, like the SDK
+// registry, so that nested Any values cannot exhaust the stack
+type statefulUnpacker struct {
+	registry *ProbeInterfaceRegistry
+	maxDepth int
+	maxCalls *sharedCounter
+}
+
+func (r statefulUnpacker) cloneForRecursion() *statefulUnpacker {
+	return &statefulUnpacker{
+		registry: r.registry,
+		maxDepth: r.maxDepth - 1,
+		maxCalls: r.maxCalls,
+	}
+}
+
+func (r *statefulUnpacker) UnpackAny(any *cosmosCodecTypes.Any, iface interface{}) error {
+	if r.maxDepth <= 0 {
+		return fmt.Errorf("max depth exceeded")
+	}
+	if r.maxCalls.count <= 0 {
+		return fmt.Errorf("call limit exceeded")
+	}
 	// here we gracefully handle the case in which `any` itself is `nil`, which may occur in message decoding
 	if any == nil {
 		return nil
@@ -163,6 +402,8 @@ func (registry *ProbeInterfaceRegistry) UnpackAny(any *cosmosCodecTypes.Any, ifa
 		return nil
 	}
 
+	r.maxCalls.count--
+
 	rv := reflect.ValueOf(iface)
 	if rv.Kind() != reflect.Ptr {
 		return fmt.Errorf("UnpackAny expects a pointer")
@@ -178,27 +419,18 @@ func (registry *ProbeInterfaceRegistry) UnpackAny(any *cosmosCodecTypes.Any, ifa
 		}
 	}
 
-	imap, found := registry.interfaceImpls[rt]
-	if !found {
-		return fmt.Errorf("no registered implementations of type %+v", rt)
-	}
-
-	typ, found := imap[any.TypeUrl]
-	if !found {
-		return fmt.Errorf("no concrete type registered for type URL %s against interface %T", any.TypeUrl, iface)
-	}
-
-	msg, ok := reflect.New(typ.Elem()).Interface().(proto.Message)
-	if !ok {
-		return fmt.Errorf("can't proto unmarshal %T", msg)
+	// The lock is released before unmarshaling, the nested Any values are unpacked recursively
+	msg, err := r.registry.newImplementation(any.TypeUrl, rt, iface)
+	if err != nil {
+		return err
 	}
 
-	err := proto.Unmarshal(any.Value, msg)
+	err = proto.Unmarshal(any.Value, msg)
 	if err != nil {
 		return err
 	}
 
-	err = UnpackInterfaces(msg, registry)
+	err = UnpackInterfaces(msg, r.cloneForRecursion())
 	if err != nil {
 		return err
 	}
@@ -225,10 +457,42 @@ func (registry *ProbeInterfaceRegistry) UnpackAny(any *cosmosCodecTypes.Any, ifa
 	return nil
 }
 
+// newImplementation returns an empty message of the implementation of rt registered under typeURL
+func (registry *ProbeInterfaceRegistry) newImplementation(typeURL string, rt reflect.Type, iface interface{}) (proto.Message, error) {
+	defer registry.rlock()()
+
+	if desc, found := registry.dynamicTypes[typeURL]; found && registry.dynamicInterfaces[typeURL] == rt {
+		return registry.newDynamicMsg(desc), nil
+	}
+
+	imap, found := registry.interfaceImpls[rt]
+	if !found {
+		return nil, fmt.Errorf("no registered implementations of type %+v", rt)
+	}
+
+	typ, found := imap[typeURL]
+	if !found {
+		return nil, fmt.Errorf("no concrete type registered for type URL %s against interface %T", typeURL, iface)
+	}
+
+	msg, ok := reflect.New(typ.Elem()).Interface().(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("can't proto unmarshal %T", msg)
+	}
+
+	return msg, nil
+}
+
 // Resolve returns the proto message given its typeURL. It works with types
 // registered with RegisterInterface/RegisterImplementations, as well as those
 // registered with RegisterWithCustomTypeURL.
 func (registry *ProbeInterfaceRegistry) Resolve(typeURL string) (proto.Message, error) {
+	defer registry.rlock()()
+
+	if desc, found := registry.dynamicTypes[typeURL]; found {
+		return registry.newDynamicMsg(desc), nil
+	}
+
 	typ, found := registry.typeURLMap[typeURL]
 	if !found {
 		return nil, fmt.Errorf("unable to resolve type URL %s", typeURL)
@@ -242,6 +506,72 @@ func (registry *ProbeInterfaceRegistry) Resolve(typeURL string) (proto.Message,
 	return msg, nil
 }
 
+// FindFileByPath looks up a file in the compiled descriptors, then in the registered descriptor sets
+func (registry *ProbeInterfaceRegistry) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	fd, err := proto.HybridResolver.FindFileByPath(path)
+	if err == nil {
+		return fd, nil
+	}
+
+	defer registry.rlock()()
+	for _, files := range registry.dynamicFiles {
+		if fd, dynErr := files.FindFileByPath(path); dynErr == nil {
+			return fd, nil
+		}
+	}
+	return nil, err
+}
+
+// FindDescriptorByName looks up a descriptor in the compiled descriptors, then in the registered
+// descriptor sets
+func (registry *ProbeInterfaceRegistry) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	d, err := proto.HybridResolver.FindDescriptorByName(name)
+	if err == nil {
+		return d, nil
+	}
+
+	defer registry.rlock()()
+	for _, files := range registry.dynamicFiles {
+		if d, dynErr := files.FindDescriptorByName(name); dynErr == nil {
+			return d, nil
+		}
+	}
+	return nil, err
+}
+
+// RangeFiles iterates over the compiled files, then over the files of the registered descriptor
+// sets that are not compiled in
+func (registry *ProbeInterfaceRegistry) RangeFiles(f func(protoreflect.FileDescriptor) bool) {
+	seen := map[string]bool{}
+	stopped := false
+	proto.HybridResolver.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		seen[fd.Path()] = true
+		stopped = !f(fd)
+		return !stopped
+	})
+	if stopped {
+		return
+	}
+
+	unlock := registry.rlock()
+	files := registry.dynamicFiles
+	unlock()
+
+	for _, dynFiles := range files {
+		dynFiles.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+			if seen[fd.Path()] {
+				return true
+			}
+			seen[fd.Path()] = true
+			stopped = !f(fd)
+			return !stopped
+		})
+		if stopped {
+			return
+		}
+	}
+}
+
 // UnpackInterfaces is a convenience function that calls UnpackInterfaces
 // on x if x implements UnpackInterfacesMessage
 func UnpackInterfaces(x interface{}, unpacker cosmosCodecTypes.AnyUnpacker) error {
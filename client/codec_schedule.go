@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	probeCodecTypes "github.com/RiemaLabs/probe/client/codec/types"
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+	sdkTypes "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/module"
+)
+
+// CodecUpgrade describes the codec of the blocks produced after a chain upgrade that changed
+// message types. The blocks are matched by height, from FromHeight on, or by the app version of
+// their header.
+//
+// Modules, CustomMsgTypeRegistry and CustomTypeRegistry default to those of the client config, and
+// the presets of the client config apply to every codec.
+type CodecUpgrade struct {
+	FromHeight     int64    `json:"from-height" yaml:"from-height"`
+	AppVersion     uint64   `json:"app-version" yaml:"app-version"`
+	DescriptorSets []string `json:"descriptor-sets" yaml:"descriptor-sets"`
+
+	Modules               []module.AppModuleBasic `json:"-" yaml:"-"`
+	CustomMsgTypeRegistry map[string]sdkTypes.Msg `json:"-" yaml:"-"`
+	CustomTypeRegistry    CustomTypeRegistry      `json:"-" yaml:"-"`
+}
+
+func (cu *CodecUpgrade) validate() error {
+	if cu.FromHeight < 0 {
+		return fmt.Errorf("codec-schedule.from-height must not be negative, got %d", cu.FromHeight)
+	}
+	if cu.FromHeight == 0 && cu.AppVersion == 0 {
+		return errors.New("codec-schedule entries require from-height or app-version")
+	}
+	return nil
+}
+
+// ScheduledCodec is a codec of the schedule of a ChainClient
+type ScheduledCodec struct {
+	FromHeight int64
+	AppVersion uint64
+	Codec      Codec
+}
+
+// makeCodecSchedule builds the codecs of the upgrades, sorted by height
+func makeCodecSchedule(ccc *ChainClientConfig) ([]ScheduledCodec, error) {
+	schedule := make([]ScheduledCodec, 0, len(ccc.CodecSchedule))
+	for _, upgrade := range ccc.CodecSchedule {
+		modules := upgrade.Modules
+		if modules == nil {
+			modules = ccc.Modules
+		}
+		customMsgTypes := upgrade.CustomMsgTypeRegistry
+		if customMsgTypes == nil {
+			customMsgTypes = ccc.CustomMsgTypeRegistry
+		}
+		customTypes := upgrade.CustomTypeRegistry
+		if customTypes == nil {
+			customTypes = ccc.CustomTypeRegistry
+		}
+
+		modules, allCustomTypes, err := ccc.codecTypes(modules, customTypes, customMsgTypes)
+		if err != nil {
+			return nil, err
+		}
+
+		codec, err := MakeCodecWithPolicy(modules, allCustomTypes, probeCodecTypes.ConflictPolicy(ccc.ConflictPolicy))
+		if err != nil {
+			return nil, fmt.Errorf("codec of height %d, app version %d: %w", upgrade.FromHeight, upgrade.AppVersion, err)
+		}
+
+		if err := codec.RegisterDescriptorSets(upgrade.DescriptorSets...); err != nil {
+			return nil, fmt.Errorf("codec of height %d, app version %d: %w", upgrade.FromHeight, upgrade.AppVersion, err)
+		}
+
+		schedule = append(schedule, ScheduledCodec{FromHeight: upgrade.FromHeight, AppVersion: upgrade.AppVersion, Codec: codec})
+	}
+
+	sort.SliceStable(schedule, func(i, j int) bool {
+		return schedule[i].FromHeight < schedule[j].FromHeight
+	})
+
+	return schedule, nil
+}
+
+// CodecForHeader returns the codec of the schedule matching the block of the header: the one with
+// its app version, else the one of the latest upgrade at or below its height. It returns fallback
+// when none matches, e.g. for the heights before the first upgrade of the schedule.
+func (cc *ChainClient) CodecForHeader(header *cmttypes.Header, fallback Codec) Codec {
+	if header == nil || len(cc.CodecSchedule) == 0 {
+		return fallback
+	}
+
+	for _, scheduled := range cc.CodecSchedule {
+		if scheduled.AppVersion != 0 && scheduled.AppVersion == header.Version.App {
+			return scheduled.Codec
+		}
+	}
+
+	codec := fallback
+	for _, scheduled := range cc.CodecSchedule {
+		if scheduled.FromHeight == 0 || scheduled.FromHeight > header.Height {
+			continue
+		}
+		codec = scheduled.Codec
+	}
+	return codec
+}
+
+// CodecAt is CodecForHeader for the block at height. The header is only fetched when the schedule
+// matches app versions.
+func (cc *ChainClient) CodecAt(ctx context.Context, height int64, fallback Codec) (Codec, error) {
+	byAppVersion := false
+	for _, scheduled := range cc.CodecSchedule {
+		byAppVersion = byAppVersion || scheduled.AppVersion != 0
+	}
+	if !byAppVersion {
+		return cc.CodecForHeader(&cmttypes.Header{Height: height}, fallback), nil
+	}
+
+	if err := cc.RequireRPC("codec schedule by app version"); err != nil {
+		return Codec{}, err
+	}
+
+	var header *coretypes.ResultHeader
+	err := cc.Retry(ctx, func(ctx context.Context) (err error) {
+		header, err = cc.RPCClient.Header(ctx, &height)
+		return err
+	})
+	if err != nil {
+		return Codec{}, HeightError(err, height)
+	}
+
+	return cc.CodecForHeader(header.Header, fallback), nil
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/cosmos/cosmos-sdk/x/params"
 	"github.com/cosmos/cosmos-sdk/x/slashing"
 	"github.com/cosmos/cosmos-sdk/x/staking"
+	"github.com/cosmos/gogoproto/proto"
 )
 
 var (
@@ -38,17 +39,68 @@ var (
 		wasm.AppModuleBasic{},
 	}
 
-	DefaultCustomMsgTypeRegistry = map[string]sdkTypes.Msg{
-		"/cosmos.crypto.PubKey": &taproot.PubKey{},
+	// DefaultCustomMsgTypeRegistry is kept for the configs that set it, custom types are now
+	// registered with CustomTypeRegistry
+	DefaultCustomMsgTypeRegistry = map[string]sdkTypes.Msg{}
+
+	// DefaultCustomTypeRegistry registers the taproot public keys of the chain under the type URL
+	// of the PubKey interface
+	DefaultCustomTypeRegistry = CustomTypeRegistry{
+		InterfacePubKey: {
+			"/cosmos.crypto.PubKey": &taproot.PubKey{},
+		},
 	}
 )
 
+// Names of the interfaces custom types are most often registered under
+const (
+	InterfaceMsg           = sdkTypes.MsgInterfaceProtoName
+	InterfacePubKey        = "cosmos.crypto.PubKey"
+	InterfaceAccount       = "cosmos.auth.v1beta1.AccountI"
+	InterfaceContent       = "cosmos.gov.v1beta1.Content"
+	InterfaceAuthorization = "cosmos.authz.v1beta1.Authorization"
+)
+
+// CustomTypeRegistry maps the name of an interface, e.g. InterfacePubKey, to the types to register
+// as its implementations, by type URL. It lets forks register their own message, public key,
+// account, proposal content or authorization types.
+type CustomTypeRegistry map[string]map[string]proto.Message
+
 type ChainClientConfig struct {
 	ChainID               string                  `json:"chain-id" yaml:"chain-id"`
+	StrictChainID         bool                    `json:"strict-chain-id" yaml:"strict-chain-id"`
 	RPCAddr               string                  `json:"rpc-addr" yaml:"rpc-addr"`
+	RPCAddrs              []string                `json:"rpc-addrs" yaml:"rpc-addrs"`
+	HealthCheckInterval   string                  `json:"health-check-interval" yaml:"health-check-interval"`
+	GRPCAddr              string                  `json:"grpc-addr" yaml:"grpc-addr"`
+	APIAddr               string                  `json:"api-addr" yaml:"api-addr"`
+	Transport             string                  `json:"transport" yaml:"transport"`
 	Debug                 bool                    `json:"debug" yaml:"debug"`
 	Timeout               string                  `json:"timeout" yaml:"timeout"`
 	OutputFormat          string                  `json:"output-format" yaml:"output-format"`
+	Retry                 *RetryPolicy            `json:"retry" yaml:"retry"`
+	VerifyProofs          bool                    `json:"verify-proofs" yaml:"verify-proofs"`
+	LightClient           *LightClientConfig      `json:"light-client" yaml:"light-client"`
+	DescriptorSets        []string                `json:"descriptor-sets" yaml:"descriptor-sets"`
+	Reflection            bool                    `json:"reflection" yaml:"reflection"`
+	ConflictPolicy        string                  `json:"conflict-policy" yaml:"conflict-policy"`
+	CodecSchedule         []CodecUpgrade          `json:"codec-schedule" yaml:"codec-schedule"`
+	Presets               []string                `json:"presets" yaml:"presets"`
 	Modules               []module.AppModuleBasic `json:"-" yaml:"-"`
 	CustomMsgTypeRegistry map[string]sdkTypes.Msg `json:"-" yaml:"-"`
+	CustomTypeRegistry    CustomTypeRegistry      `json:"-" yaml:"-"`
+}
+
+// rpcPoolAddrs returns RPCAddr followed by the RPCAddrs that are not already listed
+func (ccc *ChainClientConfig) rpcPoolAddrs() []string {
+	addrs := make([]string, 0, len(ccc.RPCAddrs)+1)
+	seen := map[string]bool{}
+	for _, addr := range append([]string{ccc.RPCAddr}, ccc.RPCAddrs...) {
+		if addr == "" || seen[addr] {
+			continue
+		}
+		seen[addr] = true
+		addrs = append(addrs, addr)
+	}
+	return addrs
 }
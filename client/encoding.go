@@ -4,8 +4,10 @@ package client
 
 import (
 	"fmt"
+	"sort"
 
 	probeCodecTypes "github.com/RiemaLabs/probe/client/codec/types"
+	"github.com/RiemaLabs/probe/logger"
 	"github.com/cosmos/cosmos-sdk/client"
 	"github.com/cosmos/cosmos-sdk/codec"
 	"github.com/cosmos/cosmos-sdk/codec/types"
@@ -13,6 +15,7 @@ import (
 	sdkTypes "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/types/module"
 	"github.com/cosmos/cosmos-sdk/x/auth/tx"
+	"github.com/cosmos/gogoproto/proto"
 )
 
 type Codec struct {
@@ -23,24 +26,107 @@ type Codec struct {
 	Amino                  *codec.LegacyAmino
 }
 
+// MakeCodec registers the given modules and custom Msg types, along with DefaultCustomTypeRegistry
 func MakeCodec(moduleBasics []module.AppModuleBasic, customMsgTypeRegistry map[string]sdkTypes.Msg) (Codec, error) {
+	return MakeCodecWithPolicy(moduleBasics, mergeCustomTypes(DefaultCustomTypeRegistry, customMsgTypeRegistry), probeCodecTypes.ConflictError)
+}
+
+// MakeCodecWithPolicy is MakeCodec with custom types of any interface, see CustomTypeRegistry, and
+// the given policy for conflicting type URLs. The resolved conflicts are listed by
+// ProbeInterfaceRegistry.Diagnostics.
+func MakeCodecWithPolicy(moduleBasics []module.AppModuleBasic, customTypeRegistry CustomTypeRegistry, policy probeCodecTypes.ConflictPolicy) (Codec, error) {
 	modBasic := module.NewBasicManager(moduleBasics...)
 	encodingConfig := MakeCodecConfig()
+	encodingConfig.ProbeInterfaceRegistry.SetConflictPolicy(policy)
 	std.RegisterLegacyAminoCodec(encodingConfig.Amino)
 	std.RegisterInterfaces(encodingConfig.InterfaceRegistry)
 	modBasic.RegisterLegacyAminoCodec(encodingConfig.Amino)
 	modBasic.RegisterInterfaces(encodingConfig.InterfaceRegistry)
 
-	for typeURL, msg := range customMsgTypeRegistry {
-		if encodingConfig.ProbeInterfaceRegistry.TypeURLIsRegistered(typeURL) {
-			return Codec{}, fmt.Errorf("error registering custom message type in codec, typeURL %s is already registered", typeURL)
+	if err := encodingConfig.ProbeInterfaceRegistry.Err(); err != nil {
+		return Codec{}, fmt.Errorf("error registering module types in codec: %w", err)
+	}
+
+	// Sorted so that conflicts between custom types are resolved the same way on every run
+	ifaceNames := make([]string, 0, len(customTypeRegistry))
+	for ifaceName := range customTypeRegistry {
+		ifaceNames = append(ifaceNames, ifaceName)
+	}
+	sort.Strings(ifaceNames)
+
+	for _, ifaceName := range ifaceNames {
+		types := customTypeRegistry[ifaceName]
+		typeURLs := make([]string, 0, len(types))
+		for typeURL := range types {
+			typeURLs = append(typeURLs, typeURL)
+		}
+		sort.Strings(typeURLs)
+
+		for _, typeURL := range typeURLs {
+			err := encodingConfig.ProbeInterfaceRegistry.RegisterCustomTypeURLForInterface(ifaceName, typeURL, types[typeURL])
+			if err != nil {
+				return Codec{}, fmt.Errorf("error registering custom type %s of interface %s in codec: %w", typeURL, ifaceName, err)
+			}
 		}
-		encodingConfig.ProbeInterfaceRegistry.RegisterCustomTypeURL((*sdkTypes.Msg)(nil), typeURL, msg)
+	}
+
+	for _, conflict := range encodingConfig.ProbeInterfaceRegistry.Diagnostics() {
+		logger.Warn("Conflicting types registered under a type URL",
+			"type_url", conflict.TypeURL, "existing", conflict.Existing, "new", conflict.New, "winner", conflict.Winner)
 	}
 
 	return encodingConfig, nil
 }
 
+// mergeCustomTypes returns the custom types with the custom Msg types added under InterfaceMsg
+func mergeCustomTypes(customTypeRegistry CustomTypeRegistry, customMsgTypeRegistry map[string]sdkTypes.Msg) CustomTypeRegistry {
+	merged := CustomTypeRegistry{}
+	merged.add(customTypeRegistry)
+
+	msgTypes := CustomTypeRegistry{InterfaceMsg: {}}
+	for typeURL, msg := range customMsgTypeRegistry {
+		msgTypes[InterfaceMsg][typeURL] = msg
+	}
+	merged.add(msgTypes)
+
+	return merged
+}
+
+// add adds the types of other, replacing those registered under the same type URL
+func (r CustomTypeRegistry) add(other CustomTypeRegistry) {
+	for ifaceName, types := range other {
+		if len(types) == 0 {
+			continue
+		}
+		if r[ifaceName] == nil {
+			r[ifaceName] = map[string]proto.Message{}
+		}
+		for typeURL, impl := range types {
+			r[ifaceName][typeURL] = impl
+		}
+	}
+}
+
+// RegisterDescriptorSets loads the FileDescriptorSets at the given paths and registers their Msg
+// types as dynamic messages, see ProbeInterfaceRegistry.RegisterFileDescriptorSet
+func (c Codec) RegisterDescriptorSets(paths ...string) error {
+	for _, path := range paths {
+		fds, err := probeCodecTypes.LoadFileDescriptorSet(path)
+		if err != nil {
+			return err
+		}
+
+		typeURLs, err := c.ProbeInterfaceRegistry.RegisterFileDescriptorSet((*sdkTypes.Msg)(nil), fds)
+		if err != nil {
+			return fmt.Errorf("error registering descriptor set %s: %w", path, err)
+		}
+
+		logger.Debug("Registered dynamic message types", "path", path, "types", len(typeURLs))
+	}
+
+	return nil
+}
+
 func MakeCodecConfig() Codec {
 	cosmosInterfaceRegistry, probeRegistry := probeCodecTypes.NewInterfaceRegistry()
 	marshaler := codec.NewProtoCodec(cosmosInterfaceRegistry)
@@ -48,7 +134,11 @@ func MakeCodecConfig() Codec {
 		ProbeInterfaceRegistry: probeRegistry,
 		InterfaceRegistry:      cosmosInterfaceRegistry,
 		Marshaler:              marshaler,
-		TxConfig:               tx.NewTxConfig(marshaler, tx.DefaultSignModes),
-		Amino:                  codec.NewLegacyAmino(),
+		TxConfig: probeTxConfig{
+			TxConfig: tx.NewTxConfig(marshaler, tx.DefaultSignModes),
+			cdc:      marshaler,
+			registry: probeRegistry,
+		},
+		Amino: codec.NewLegacyAmino(),
 	}
 }
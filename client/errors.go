@@ -0,0 +1,237 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"syscall"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	legacyerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// ErrHeightNotAvailable is returned when the requested height is above the latest height of the node
+	ErrHeightNotAvailable = errors.New("height not available")
+
+	// ErrPruned is returned when the requested height has been pruned by the node
+	ErrPruned = errors.New("height pruned")
+
+	// ErrNotFound is returned when the requested tx, block or state entry does not exist
+	ErrNotFound = errors.New("not found")
+
+	// ErrTimeout is returned when the node did not answer before the deadline
+	ErrTimeout = errors.New("timeout")
+
+	// ErrChainIDMismatch is returned when a node or a block belongs to another chain than Config.ChainID
+	ErrChainIDMismatch = errors.New("chain id mismatch")
+
+	// ErrTxIndexingDisabled is returned by tx searches and lookups on nodes running with indexer = "null"
+	ErrTxIndexingDisabled = errors.New("tx indexing disabled")
+
+	// ErrABCICode is matched by every ABCIError, i.e. when the app returned a non-zero code
+	ErrABCICode = errors.New("abci error code")
+)
+
+// ABCIError is returned when an ABCI query or tx comes back with a non-zero code.
+//
+// It matches ErrABCICode with errors.Is, as well as ErrNotFound, ErrPruned and ErrHeightNotAvailable
+// when the code or log identify them. It also carries a gRPC status so that status.Code keeps
+// working for errors returned through the gRPC ClientConn.
+type ABCIError struct {
+	Codespace string
+	Code      uint32
+	Log       string
+}
+
+func NewABCIError(res abci.ResponseQuery) *ABCIError {
+	return &ABCIError{
+		Codespace: res.Codespace,
+		Code:      res.Code,
+		Log:       res.Log,
+	}
+}
+
+func (e *ABCIError) Error() string {
+	return fmt.Sprintf("abci error (codespace: %s, code: %d): %s", e.Codespace, e.Code, e.Log)
+}
+
+func (e *ABCIError) Is(target error) bool {
+	switch target {
+	case ErrABCICode:
+		return true
+	case ErrNotFound:
+		return e.isSDKCode(legacyerrors.ErrKeyNotFound) || e.isSDKCode(legacyerrors.ErrNotFound)
+	default:
+		return target != nil && errors.Is(classifyMessage(e.Log), target)
+	}
+}
+
+// GRPCStatus maps the ABCI code to a gRPC status
+func (e *ABCIError) GRPCStatus() *status.Status {
+	switch {
+	case e.isSDKCode(legacyerrors.ErrInvalidRequest):
+		return status.New(codes.InvalidArgument, e.Log)
+	case e.isSDKCode(legacyerrors.ErrUnauthorized):
+		return status.New(codes.Unauthenticated, e.Log)
+	case e.isSDKCode(legacyerrors.ErrKeyNotFound):
+		return status.New(codes.NotFound, e.Log)
+	default:
+		return status.New(codes.Unknown, e.Log)
+	}
+}
+
+func (e *ABCIError) isSDKCode(sdkErr interface {
+	Codespace() string
+	ABCICode() uint32
+}) bool {
+	return e.Codespace == sdkErr.Codespace() && e.Code == sdkErr.ABCICode()
+}
+
+// HeightError adds the queried height to pruned and not yet available height errors
+func HeightError(err error, height int64) error {
+	switch {
+	case height <= 0:
+		return err
+	case errors.Is(err, ErrPruned):
+		return fmt.Errorf("state at height %d has been pruned by the node, use an archive node: %w", height, err)
+	case errors.Is(err, ErrHeightNotAvailable):
+		return fmt.Errorf("height %d has not been reached by the node yet: %w", height, err)
+	}
+	return err
+}
+
+// ClassifyError wraps err with the matching sentinel error, so callers can branch with errors.Is.
+// Errors that are already classified or that cannot be classified are returned unchanged.
+func ClassifyError(err error) error {
+	if err == nil || isClassified(err) {
+		return err
+	}
+
+	if kind := classify(err); kind != nil {
+		return fmt.Errorf("%w: %w", kind, err)
+	}
+
+	return err
+}
+
+// IsTransient reports whether a failed call is worth retrying. Timeouts, refused or reset
+// connections, unexpected EOFs and server errors are transient. Other network errors, like
+// certificate or unknown host errors, are not, retrying does not fix them. Neither are pruned
+// heights, missing entries, disabled tx indexing, app errors and cancellations. Heights the node
+// has not reached yet are not transient either, as the height may be far in the future, see
+// RetryPolicy.RetryHeightNotAvailable.
+func IsTransient(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, context.Canceled):
+		return false
+	case errors.Is(err, ErrTimeout):
+		return true
+	case errors.Is(err, ErrHeightNotAvailable), errors.Is(err, ErrPruned), errors.Is(err, ErrNotFound),
+		errors.Is(err, ErrTxIndexingDisabled), errors.Is(err, ErrABCICode):
+		return false
+	}
+
+	if s, ok := status.FromError(err); ok {
+		switch s.Code() {
+		case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+			return true
+		}
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	msg := err.Error()
+	for _, transient := range transientMessages {
+		if strings.Contains(msg, transient) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var transientMessages = []string{
+	"connection refused",
+	"connection reset",
+	"broken pipe",
+	"Status: 429",
+	"Status: 500",
+	"Status: 502",
+	"Status: 503",
+	"Status: 504",
+}
+
+func isClassified(err error) bool {
+	for _, kind := range []error{ErrHeightNotAvailable, ErrPruned, ErrNotFound, ErrTimeout, ErrTxIndexingDisabled, ErrABCICode} {
+		if errors.Is(err, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+func classify(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrTimeout
+	}
+
+	if s, ok := status.FromError(err); ok {
+		switch s.Code() {
+		case codes.NotFound:
+			return ErrNotFound
+		case codes.DeadlineExceeded:
+			return ErrTimeout
+		}
+	}
+
+	return classifyMessage(err.Error())
+}
+
+// txNotFoundRegexp matches the error of the CometBFT /tx endpoint for an unknown hash
+var txNotFoundRegexp = regexp.MustCompile(`tx \([0-9A-Fa-f]+\) not found`)
+
+// classifyMessage parses the error messages of the CometBFT RPC and of the SDK query handlers
+func classifyMessage(msg string) error {
+	switch {
+	case strings.Contains(msg, "must be less than or equal to the current blockchain height"),
+		strings.Contains(msg, "cannot query with height in the future"):
+		return ErrHeightNotAvailable
+	case strings.Contains(msg, "is not available, lowest height is"),
+		strings.Contains(msg, "failed to load state at height"),
+		strings.Contains(msg, "version does not exist"),
+		strings.Contains(msg, "could not find results for height"):
+		return ErrPruned
+	case strings.Contains(msg, "transaction indexing is disabled"):
+		return ErrTxIndexingDisabled
+	case txNotFoundRegexp.MatchString(msg), strings.Contains(msg, "tx not found"):
+		return ErrNotFound
+	case strings.Contains(msg, "Client.Timeout exceeded"),
+		strings.Contains(msg, "context deadline exceeded"),
+		strings.Contains(msg, "timed out"):
+		return ErrTimeout
+	}
+
+	return nil
+}
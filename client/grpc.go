@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/RiemaLabs/probe/logger"
+	"github.com/cosmos/cosmos-sdk/codec"
+	"github.com/cosmos/cosmos-sdk/codec/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// TransportRPC sends every query through the CometBFT RPC, module queries as ABCI queries
+	TransportRPC = "rpc"
+	// TransportGRPC sends module queries and tx lookups to the app gRPC server, block data stays on the CometBFT RPC
+	TransportGRPC = "grpc"
+	// TransportBoth prefers the app gRPC server and falls back to the CometBFT RPC when it is unreachable
+	TransportBoth = "both"
+)
+
+// NewGRPCConn creates a connection to the gRPC server of the app (usually on port 9090).
+//
+// The address is a host:port pair. A https:// prefix enables TLS, a http:// prefix is ignored.
+// The codec is plugged in as the gRPC codec, so Any fields are unpacked with the probe registry.
+func NewGRPCConn(addr string, cdc Codec) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	switch {
+	case strings.HasPrefix(addr, "https://"):
+		addr = strings.TrimPrefix(addr, "https://")
+		creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	case strings.HasPrefix(addr, "http://"):
+		addr = strings.TrimPrefix(addr, "http://")
+	}
+	addr = strings.TrimSuffix(addr, "/")
+
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+
+	provider, ok := cdc.Marshaler.(codec.GRPCCodecProvider)
+	if !ok {
+		return nil, fmt.Errorf("codec %T does not provide a gRPC codec", cdc.Marshaler)
+	}
+	opts = append(opts, grpc.WithDefaultCallOptions(grpc.ForceCodec(provider.GRPCCodec())))
+
+	return grpc.NewClient(addr, opts...)
+}
+
+// UsesGRPC reports whether module queries are sent to the app gRPC server
+func (cc *ChainClient) UsesGRPC() bool {
+	return cc.GRPCConn != nil && (cc.Config.Transport == TransportGRPC || cc.Config.Transport == TransportBoth)
+}
+
+func (cc *ChainClient) invokeGRPC(ctx context.Context, method string, req, reply interface{}, opts ...grpc.CallOption) error {
+	err := cc.Retry(ctx, func(ctx context.Context) error {
+		return cc.GRPCConn.Invoke(ctx, method, req, reply, opts...)
+	})
+	if err != nil {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		height, _ := GetHeightFromMetadata(md)
+		return HeightError(err, height)
+	}
+
+	return types.UnpackInterfaces(reply, cc.Codec.InterfaceRegistry)
+}
+
+// shouldFallback reports whether a failed gRPC call should be retried over the CometBFT RPC
+func (cc *ChainClient) shouldFallback(err error) bool {
+	if cc.Config.Transport != TransportBoth || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	s, ok := status.FromError(err)
+	if !ok {
+		return true
+	}
+
+	switch s.Code() {
+	case codes.Unavailable, codes.Unimplemented, codes.DeadlineExceeded:
+		logger.Debug("gRPC query failed, falling back to RPC", "code", s.Code().String(), "error", s.Message())
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,189 @@
+// APACHE NOTICE
+// Sourced with modifications from https://github.com/strangelove-ventures/lens
+package client
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	errorsmod "cosmossdk.io/errors"
+	abci "github.com/cometbft/cometbft/abci/types"
+	rpcclient "github.com/cometbft/cometbft/rpc/client"
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	"github.com/cosmos/cosmos-sdk/codec/types"
+	legacyerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	grpctypes "github.com/cosmos/cosmos-sdk/types/grpc"
+	gogogrpc "github.com/cosmos/gogoproto/grpc"
+	"github.com/cosmos/gogoproto/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+var _ gogogrpc.ClientConn = &ChainClient{}
+
+// Invoke implements the gogoproto grpc.ClientConn interface. The request is
+// encoded with the client codec and sent as an ABCI query on the gRPC method
+// path, so every generated module QueryClient can be used with a ChainClient.
+// When the gRPC transport is configured, the call goes to the app gRPC server instead.
+func (cc *ChainClient) Invoke(ctx context.Context, method string, req, reply interface{}, opts ...grpc.CallOption) error {
+	// We don't allow an empty request, it would panic unexpectedly when marshaling.
+	if req == nil || reflect.ValueOf(req).IsNil() {
+		return errorsmod.Wrap(legacyerrors.ErrInvalidRequest, "request cannot be nil")
+	}
+
+	if cc.UsesGRPC() {
+		err := cc.invokeGRPC(ctx, method, req, reply, opts...)
+		if err == nil || !cc.shouldFallback(err) {
+			return err
+		}
+	}
+
+	inMd, _ := metadata.FromOutgoingContext(ctx)
+	abciRes, outMd, err := cc.RunGRPCQuery(ctx, method, req, inMd)
+	if err != nil {
+		return err
+	}
+
+	replyMsg, ok := reply.(proto.Message)
+	if !ok {
+		return fmt.Errorf("reply %T is not a proto message", reply)
+	}
+
+	if err := cc.Codec.Marshaler.Unmarshal(abciRes.Value, replyMsg); err != nil {
+		return err
+	}
+
+	for _, callOpt := range opts {
+		header, ok := callOpt.(grpc.HeaderCallOption)
+		if !ok {
+			continue
+		}
+
+		*header.HeaderAddr = outMd
+	}
+
+	if cc.Codec.InterfaceRegistry != nil {
+		return types.UnpackInterfaces(reply, cc.Codec.InterfaceRegistry)
+	}
+
+	return nil
+}
+
+// NewStream implements the gogoproto grpc.ClientConn interface. Streaming is
+// not supported over ABCI queries.
+func (cc *ChainClient) NewStream(context.Context, *grpc.StreamDesc, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+	return nil, fmt.Errorf("streaming rpc not supported")
+}
+
+// RunGRPCQuery runs a gRPC query over the ABCI query endpoint of the RPC client
+// and returns the raw ABCI response along with the response header metadata.
+func (cc *ChainClient) RunGRPCQuery(ctx context.Context, method string, req interface{}, md metadata.MD) (abci.ResponseQuery, metadata.MD, error) {
+	reqMsg, ok := req.(proto.Message)
+	if !ok {
+		return abci.ResponseQuery{}, nil, fmt.Errorf("request %T is not a proto message", req)
+	}
+
+	reqBz, err := cc.Codec.Marshaler.Marshal(reqMsg)
+	if err != nil {
+		return abci.ResponseQuery{}, nil, err
+	}
+
+	height, err := GetHeightFromMetadata(md)
+	if err != nil {
+		return abci.ResponseQuery{}, nil, err
+	}
+
+	prove, err := GetProveFromMetadata(md)
+	if err != nil {
+		return abci.ResponseQuery{}, nil, err
+	}
+
+	abciReq := abci.RequestQuery{
+		Path:   method,
+		Data:   reqBz,
+		Height: height,
+		Prove:  prove,
+	}
+
+	abciRes, err := cc.QueryABCI(ctx, abciReq)
+	if err != nil {
+		return abci.ResponseQuery{}, nil, err
+	}
+
+	// Create header metadata. For now the headers contain:
+	// - block height
+	// We then parse all the call options, if the call option is a
+	// HeaderCallOption, then we manually set the value of that header to the
+	// metadata.
+	md = metadata.Pairs(grpctypes.GRPCBlockHeightHeader, strconv.FormatInt(abciRes.Height, 10))
+
+	return abciRes, md, nil
+}
+
+// QueryABCI performs an ABCI query and returns the appropriate response and error sdk error code.
+func (cc *ChainClient) QueryABCI(ctx context.Context, req abci.RequestQuery) (abci.ResponseQuery, error) {
+	if err := cc.RequireRPC("abci query"); err != nil {
+		return abci.ResponseQuery{}, err
+	}
+
+	opts := rpcclient.ABCIQueryOptions{
+		Height: req.Height,
+		Prove:  req.Prove,
+	}
+
+	var result *coretypes.ResultABCIQuery
+	err := cc.Retry(ctx, func(ctx context.Context) (err error) {
+		result, err = cc.RPCClient.ABCIQueryWithOptions(ctx, req.Path, req.Data, opts)
+		return err
+	})
+	if err != nil {
+		return abci.ResponseQuery{}, HeightError(err, req.Height)
+	}
+
+	// The returned ABCIError carries the matching gRPC status code
+	if !result.Response.IsOK() {
+		return abci.ResponseQuery{}, HeightError(NewABCIError(result.Response), req.Height)
+	}
+
+	return result.Response, nil
+}
+
+// SetHeightOnContext returns a context that pins gRPC queries made with it to the given height.
+func SetHeightOnContext(ctx context.Context, height int64) context.Context {
+	if height > 0 {
+		return metadata.AppendToOutgoingContext(ctx, grpctypes.GRPCBlockHeightHeader, strconv.FormatInt(height, 10))
+	}
+	return ctx
+}
+
+// GetHeightFromMetadata returns the height set in the gRPC metadata, or 0 if none is set.
+func GetHeightFromMetadata(md metadata.MD) (int64, error) {
+	height := md.Get(grpctypes.GRPCBlockHeightHeader)
+	if len(height) == 1 {
+		h, err := strconv.ParseInt(height[0], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		if h < 0 {
+			return 0, errorsmod.Wrapf(legacyerrors.ErrInvalidRequest,
+				"height (%d) from %q must be >= 0", h, grpctypes.GRPCBlockHeightHeader)
+		}
+		return h, nil
+	}
+	return 0, nil
+}
+
+// GetProveFromMetadata returns whether a proof was requested in the gRPC metadata.
+func GetProveFromMetadata(md metadata.MD) (bool, error) {
+	prove := md.Get("x-cosmos-query-prove")
+	if len(prove) == 1 {
+		p, err := strconv.ParseBool(prove[0])
+		if err != nil {
+			return false, err
+		}
+		return p, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,182 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/RiemaLabs/probe/logger"
+	dbm "github.com/cometbft/cometbft-db"
+	"github.com/cometbft/cometbft/light"
+	dbs "github.com/cometbft/cometbft/light/store/db"
+	cmttypes "github.com/cometbft/cometbft/types"
+)
+
+const (
+	// DefaultTrustingPeriod is used when the light client config sets none. It must stay below the
+	// unbonding period of the chain.
+	DefaultTrustingPeriod = 168 * time.Hour
+
+	lightClientDBName = "probe-light"
+)
+
+// errNoWitness is returned when the light client has no witness and none is explicitly allowed
+var errNoWitness = errors.New("light-client requires a witness, set light-client.witnesses or more rpc-addrs, or light-client.allow-no-witness to check the primary against itself")
+
+// ErrLightClientVerification is returned when a block or header fetched from the RPC does not match
+// the one verified by the light client
+var ErrLightClientVerification = errors.New("light client verification failed")
+
+// LightClientConfig enables the verification of every block and header fetched by probe with the
+// CometBFT light client, so that untrusted RPC nodes can be used.
+type LightClientConfig struct {
+	// TrustedHeight and TrustedHash identify a block obtained from a trusted source
+	TrustedHeight int64  `json:"trusted-height" yaml:"trusted-height"`
+	TrustedHash   string `json:"trusted-hash" yaml:"trusted-hash"`
+	// TrustingPeriod defaults to DefaultTrustingPeriod
+	TrustingPeriod string `json:"trusting-period" yaml:"trusting-period"`
+	// Witnesses are the RPC addresses used to cross-check the primary, defaults to the RPC pool addresses
+	Witnesses []string `json:"witnesses" yaml:"witnesses"`
+	// AllowNoWitness runs the light client without witness, the primary is then only checked
+	// against itself and a lying primary is not detected
+	AllowNoWitness bool `json:"allow-no-witness" yaml:"allow-no-witness"`
+	// DBDir holds the trusted store, defaults to ~/.probe/light
+	DBDir string `json:"db-dir" yaml:"db-dir"`
+	// Sequential verifies every header between two heights instead of skipping
+	Sequential bool `json:"sequential" yaml:"sequential"`
+}
+
+func (lcc *LightClientConfig) validate() error {
+	var errs []error
+
+	if lcc.TrustedHeight <= 0 {
+		errs = append(errs, fmt.Errorf("light-client.trusted-height must be positive, got %d", lcc.TrustedHeight))
+	}
+
+	if hash, err := hex.DecodeString(lcc.TrustedHash); err != nil || len(hash) != 32 {
+		errs = append(errs, fmt.Errorf("light-client.trusted-hash must be a hex encoded sha256 hash, got %q", lcc.TrustedHash))
+	}
+
+	if lcc.TrustingPeriod != "" {
+		if err := validatePositiveDuration("light-client.trusting-period", lcc.TrustingPeriod); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// NewLightClient creates a light client for the chain, with primary as the primary RPC address.
+// The trusted store is kept on disk so that later runs resume from the latest verified header, the
+// caller closes the returned store once done with the light client. Without witnesses, an error is
+// returned unless LightClientConfig.AllowNoWitness is set.
+func NewLightClient(ctx context.Context, chainID string, primary string, witnesses []string, lcc *LightClientConfig) (*light.Client, dbm.DB, error) {
+	hash, err := hex.DecodeString(lcc.TrustedHash)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid trusted hash: %w", err)
+	}
+
+	period := DefaultTrustingPeriod
+	if lcc.TrustingPeriod != "" {
+		period, err = time.ParseDuration(lcc.TrustingPeriod)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid trusting period: %w", err)
+		}
+	}
+
+	// The light client needs a witness, a single node can only be checked against itself
+	if len(witnesses) == 0 {
+		if !lcc.AllowNoWitness {
+			return nil, nil, errNoWitness
+		}
+		logger.Warn("No light client witness configured, the primary cannot be cross-checked", "primary", primary)
+		witnesses = []string{primary}
+	}
+
+	dir := lcc.DBDir
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, nil, err
+		}
+		dir = filepath.Join(home, ".probe", "light")
+	}
+
+	db, err := dbm.NewGoLevelDB(lightClientDBName, dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open the light client store in %s: %w", dir, err)
+	}
+
+	opts := []light.Option{light.SkippingVerification(light.DefaultTrustLevel)}
+	if lcc.Sequential {
+		opts = []light.Option{light.SequentialVerification()}
+	}
+
+	lc, err := light.NewHTTPClient(ctx, chainID, light.TrustOptions{
+		Period: period,
+		Height: lcc.TrustedHeight,
+		Hash:   hash,
+	}, primary, witnesses, dbs.New(db, chainID), opts...)
+	if err != nil {
+		_ = db.Close()
+		return nil, nil, fmt.Errorf("failed to create light client: %w", err)
+	}
+
+	return lc, db, nil
+}
+
+func (cc *ChainClient) initLightClient() error {
+	lcc := cc.Config.LightClient
+	if lcc == nil {
+		return nil
+	}
+
+	addrs := cc.Config.rpcPoolAddrs()
+	if len(addrs) == 0 {
+		return fmt.Errorf("the light client requires an rpc address")
+	}
+
+	witnesses := lcc.Witnesses
+	if len(witnesses) == 0 {
+		witnesses = addrs[1:]
+	}
+
+	lc, db, err := NewLightClient(context.Background(), cc.Config.ChainID, addrs[0], witnesses, lcc)
+	if err != nil {
+		return err
+	}
+
+	cc.LightClient, cc.lightDB = lc, db
+	return nil
+}
+
+// VerifyHeader checks the header against the light client, if one is configured
+func (cc *ChainClient) VerifyHeader(ctx context.Context, header *cmttypes.Header) error {
+	if cc.LightClient == nil || header == nil {
+		return nil
+	}
+
+	return cc.verifyLightBlock(ctx, header.Height, header.Hash())
+}
+
+// verifyLightBlock verifies the header at height with the light client and compares its hash
+func (cc *ChainClient) verifyLightBlock(ctx context.Context, height int64, hash []byte) error {
+	cc.lightMtx.Lock()
+	lb, err := cc.LightClient.VerifyLightBlockAtHeight(ctx, height, time.Now())
+	cc.lightMtx.Unlock()
+	if err != nil {
+		return fmt.Errorf("%w: height %d: %w", ErrLightClientVerification, height, err)
+	}
+
+	if !bytes.Equal(lb.Hash(), hash) {
+		return fmt.Errorf("%w: height %d has hash %X, the light client verified %X",
+			ErrLightClientVerification, height, hash, lb.Hash())
+	}
+
+	return nil
+}
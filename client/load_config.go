@@ -0,0 +1,336 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	probeCodecTypes "github.com/RiemaLabs/probe/client/codec/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix prefixes the environment variables that override the config, e.g. PROBE_RPC_ADDR
+// overrides rpc-addr and PROBE_RETRY_MAX_ATTEMPTS overrides retry.max-attempts.
+const EnvPrefix = "PROBE"
+
+// OutputFormats lists the supported values of ChainClientConfig.OutputFormat
+var OutputFormats = []string{"json", "indent", "yaml", "text"}
+
+var chainIDRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// LoadConfig reads a YAML (.yaml, .yml) or JSON (.json) config file, applies the PROBE_*
+// environment overrides and validates the result. Unknown keys are rejected.
+//
+// Modules, CustomMsgTypeRegistry and CustomTypeRegistry cannot be set from a file, they default to
+// DefaultModuleBasics, DefaultCustomMsgTypeRegistry and DefaultCustomTypeRegistry. Modules has no
+// default when presets are listed.
+func LoadConfig(path string) (*ChainClientConfig, error) {
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ccc := &ChainClientConfig{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(bz))
+		dec.KnownFields(true)
+		// An empty file is decoded as io.EOF and leaves the config empty
+		if err := dec.Decode(ccc); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(bz))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(ccc); err != nil {
+			return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config format %q, expected .yaml, .yml or .json", ext)
+	}
+
+	if err := applyEnv(reflect.ValueOf(ccc).Elem(), EnvPrefix); err != nil {
+		return nil, err
+	}
+
+	// The presets replace the default modules
+	if ccc.Modules == nil && len(ccc.Presets) == 0 {
+		ccc.Modules = DefaultModuleBasics
+	}
+	if ccc.CustomMsgTypeRegistry == nil {
+		ccc.CustomMsgTypeRegistry = DefaultCustomMsgTypeRegistry
+	}
+	if ccc.CustomTypeRegistry == nil {
+		ccc.CustomTypeRegistry = DefaultCustomTypeRegistry
+	}
+
+	if err := ccc.Validate(); err != nil {
+		return nil, err
+	}
+
+	return ccc, nil
+}
+
+// Validate checks the config and returns all the problems found, joined with errors.Join
+func (ccc *ChainClientConfig) Validate() error {
+	var errs []error
+
+	if ccc.ChainID != "" {
+		if len(ccc.ChainID) > cmttypes.MaxChainIDLen {
+			errs = append(errs, fmt.Errorf("chain-id %q is longer than %d characters", ccc.ChainID, cmttypes.MaxChainIDLen))
+		} else if !chainIDRegexp.MatchString(ccc.ChainID) {
+			errs = append(errs, fmt.Errorf("chain-id %q must be made of letters, digits, '.', '_' and '-'", ccc.ChainID))
+		}
+	}
+
+	if ccc.VerifyProofs && (ccc.Transport == TransportGRPC || ccc.Transport == TransportREST) {
+		errs = append(errs, fmt.Errorf("verify-proofs requires the rpc transport, got %s", ccc.Transport))
+	}
+
+	if ccc.LightClient != nil {
+		if ccc.ChainID == "" {
+			errs = append(errs, errors.New("light-client requires chain-id"))
+		}
+		if ccc.Transport == TransportREST {
+			errs = append(errs, fmt.Errorf("light-client requires an rpc address, got transport %s", ccc.Transport))
+		}
+		if err := ccc.LightClient.validate(); err != nil {
+			errs = append(errs, err)
+		}
+		// Without witness, the first rpc address is checked against the others
+		if len(ccc.LightClient.Witnesses) == 0 && len(ccc.rpcPoolAddrs()) < 2 && !ccc.LightClient.AllowNoWitness {
+			errs = append(errs, errNoWitness)
+		}
+	}
+
+	if ccc.StrictChainID && ccc.ChainID == "" {
+		errs = append(errs, errors.New("strict-chain-id requires chain-id"))
+	}
+
+	switch ccc.Transport {
+	case "", TransportRPC, TransportGRPC, TransportBoth, TransportREST:
+	default:
+		errs = append(errs, fmt.Errorf("unknown transport %q, expected %s, %s, %s or %s",
+			ccc.Transport, TransportRPC, TransportGRPC, TransportBoth, TransportREST))
+	}
+
+	addrs := ccc.rpcPoolAddrs()
+	if len(addrs) == 0 && ccc.Transport != TransportGRPC && ccc.Transport != TransportREST {
+		errs = append(errs, errors.New("rpc-addr is required"))
+	}
+	for _, addr := range addrs {
+		if err := validateURL(addr, "http", "https", "tcp"); err != nil {
+			errs = append(errs, fmt.Errorf("invalid rpc address: %w", err))
+		}
+	}
+
+	if (ccc.Transport == TransportGRPC || ccc.Transport == TransportBoth) && ccc.GRPCAddr == "" {
+		errs = append(errs, fmt.Errorf("transport %s requires grpc-addr", ccc.Transport))
+	}
+	if ccc.Transport == TransportREST {
+		if ccc.APIAddr == "" {
+			errs = append(errs, fmt.Errorf("transport %s requires api-addr", ccc.Transport))
+		} else if err := validateURL(ccc.APIAddr, "http", "https"); err != nil {
+			errs = append(errs, fmt.Errorf("invalid api address: %w", err))
+		}
+	}
+
+	timeout, err := time.ParseDuration(ccc.Timeout)
+	switch {
+	case err != nil:
+		errs = append(errs, fmt.Errorf("invalid timeout %q: %w", ccc.Timeout, err))
+	case timeout <= 0:
+		errs = append(errs, fmt.Errorf("timeout must be positive, got %s", ccc.Timeout))
+	}
+
+	if ccc.HealthCheckInterval != "" {
+		if err := validatePositiveDuration("health-check-interval", ccc.HealthCheckInterval); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if ccc.OutputFormat != "" && !contains(OutputFormats, ccc.OutputFormat) {
+		errs = append(errs, fmt.Errorf("unknown output-format %q, expected one of %s",
+			ccc.OutputFormat, strings.Join(OutputFormats, ", ")))
+	}
+
+	if ccc.ConflictPolicy != "" && !contains(conflictPolicies(), ccc.ConflictPolicy) {
+		errs = append(errs, fmt.Errorf("unknown conflict-policy %q, expected one of %s",
+			ccc.ConflictPolicy, strings.Join(conflictPolicies(), ", ")))
+	}
+
+	for _, preset := range ccc.Presets {
+		if _, ok := ModulePresets[preset]; !ok {
+			errs = append(errs, fmt.Errorf("unknown preset %q, expected one of %s", preset, strings.Join(presetNames(), ", ")))
+		}
+	}
+
+	for i := range ccc.CodecSchedule {
+		if err := ccc.CodecSchedule[i].validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if ccc.Retry != nil {
+		if ccc.Retry.MaxAttempts < 0 {
+			errs = append(errs, fmt.Errorf("retry.max-attempts must not be negative, got %d", ccc.Retry.MaxAttempts))
+		}
+		if ccc.Retry.InitialBackoff != "" {
+			if err := validatePositiveDuration("retry.initial-backoff", ccc.Retry.InitialBackoff); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if ccc.Retry.MaxBackoff != "" {
+			if err := validatePositiveDuration("retry.max-backoff", ccc.Retry.MaxBackoff); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if ccc.Retry.Jitter < 0 || ccc.Retry.Jitter > 1 {
+			errs = append(errs, fmt.Errorf("retry.jitter must be between 0 and 1, got %g", ccc.Retry.Jitter))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func conflictPolicies() []string {
+	policies := make([]string, 0, len(probeCodecTypes.ConflictPolicies))
+	for _, policy := range probeCodecTypes.ConflictPolicies {
+		policies = append(policies, string(policy))
+	}
+	return policies
+}
+
+func validateURL(addr string, schemes ...string) error {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return err
+	}
+	if !contains(schemes, u.Scheme) {
+		return fmt.Errorf("%q must start with %s://", addr, strings.Join(schemes, ":// or "))
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", addr)
+	}
+	return nil
+}
+
+func validatePositiveDuration(name, value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", name, value, err)
+	}
+	if d <= 0 {
+		return fmt.Errorf("%s must be positive, got %s", name, value)
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// applyEnv overrides the fields of the config struct v with the environment variables named after
+// their yaml tags. Nested structs are prefixed with the name of their field.
+func applyEnv(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		name := prefix + "_" + strings.ToUpper(strings.ReplaceAll(tag, "-", "_"))
+		fv := v.Field(i)
+
+		// Nested configs such as retry are created when one of their variables is set
+		if field.Type.Kind() == reflect.Pointer && field.Type.Elem().Kind() == reflect.Struct {
+			if !envHasPrefix(name + "_") {
+				continue
+			}
+			if fv.IsNil() {
+				fv.Set(reflect.New(field.Type.Elem()))
+			}
+			if err := applyEnv(fv.Elem(), name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		if err := setEnvValue(fv, value); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func setEnvValue(fv reflect.Value, value string) error {
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", fv.Type())
+		}
+		// Lists are comma separated
+		var values []string
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		fv.Set(reflect.ValueOf(values))
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
+
+func envHasPrefix(prefix string) bool {
+	for _, env := range os.Environ() {
+		if strings.HasPrefix(env, prefix) {
+			return true
+		}
+	}
+	return false
+}
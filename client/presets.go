@@ -0,0 +1,146 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"cosmossdk.io/x/evidence"
+	feegrant "cosmossdk.io/x/feegrant/module"
+	"cosmossdk.io/x/upgrade"
+	"github.com/CosmWasm/wasmd/x/wasm"
+	sdkTypes "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/module"
+	"github.com/cosmos/cosmos-sdk/x/auth"
+	"github.com/cosmos/cosmos-sdk/x/auth/vesting"
+	authz "github.com/cosmos/cosmos-sdk/x/authz/module"
+	"github.com/cosmos/cosmos-sdk/x/bank"
+	"github.com/cosmos/cosmos-sdk/x/consensus"
+	"github.com/cosmos/cosmos-sdk/x/crisis"
+	"github.com/cosmos/cosmos-sdk/x/distribution"
+	"github.com/cosmos/cosmos-sdk/x/gov"
+	group "github.com/cosmos/cosmos-sdk/x/group/module"
+	"github.com/cosmos/cosmos-sdk/x/mint"
+	"github.com/cosmos/cosmos-sdk/x/params"
+	"github.com/cosmos/cosmos-sdk/x/slashing"
+	"github.com/cosmos/cosmos-sdk/x/staking"
+	ica "github.com/cosmos/ibc-go/v10/modules/apps/27-interchain-accounts"
+	"github.com/cosmos/ibc-go/v10/modules/apps/transfer"
+	ibc "github.com/cosmos/ibc-go/v10/modules/core"
+	solomachine "github.com/cosmos/ibc-go/v10/modules/light-clients/06-solomachine"
+	ibctm "github.com/cosmos/ibc-go/v10/modules/light-clients/07-tendermint"
+)
+
+// ModulePreset bundles the modules and custom types needed to decode the txs of a family of chains
+type ModulePreset struct {
+	Modules            []module.AppModuleBasic
+	CustomTypeRegistry CustomTypeRegistry
+}
+
+var (
+	cosmosSDKModules = []module.AppModuleBasic{
+		auth.AppModuleBasic{},
+		authz.AppModuleBasic{},
+		bank.AppModuleBasic{},
+		gov.AppModuleBasic{},
+		crisis.AppModuleBasic{},
+		distribution.AppModuleBasic{},
+		mint.AppModuleBasic{},
+		params.AppModuleBasic{},
+		slashing.AppModuleBasic{},
+		staking.AppModuleBasic{},
+		vesting.AppModuleBasic{},
+	}
+
+	cosmosSDKFullModules = append(append([]module.AppModuleBasic{}, cosmosSDKModules...),
+		feegrant.AppModuleBasic{},
+		upgrade.AppModuleBasic{},
+		evidence.AppModuleBasic{},
+		consensus.AppModuleBasic{},
+		group.AppModuleBasic{},
+	)
+
+	ibcModules = []module.AppModuleBasic{
+		ibc.AppModuleBasic{},
+		transfer.AppModuleBasic{},
+		ica.AppModuleBasic{},
+		ibctm.AppModuleBasic{},
+		solomachine.AppModuleBasic{},
+	}
+
+	// ModulePresets are the presets that can be listed in ChainClientConfig.Presets. They can be
+	// combined, e.g. "cosmos-sdk-full" and "ibc", the modules shared by several presets are
+	// registered once.
+	//
+	// The circuit module is not included, its module is not a dependency of probe.
+	ModulePresets = map[string]ModulePreset{
+		// cosmos-sdk holds the modules of DefaultModuleBasics
+		"cosmos-sdk": {Modules: cosmosSDKModules},
+		// cosmos-sdk-full adds feegrant, upgrade, evidence, consensus and group
+		"cosmos-sdk-full": {Modules: cosmosSDKFullModules},
+		// ibc holds IBC core, transfer, interchain accounts and the light clients
+		"ibc":  {Modules: ibcModules},
+		"wasm": {Modules: []module.AppModuleBasic{wasm.AppModuleBasic{}}},
+		// thunderbolt decodes the chains of the thunderbolt SDK fork, with their taproot public keys
+		"thunderbolt": {
+			Modules:            append(append(append([]module.AppModuleBasic{}, cosmosSDKFullModules...), ibcModules...), wasm.AppModuleBasic{}),
+			CustomTypeRegistry: DefaultCustomTypeRegistry,
+		},
+	}
+)
+
+// ResolvePresets combines the named presets. The modules are returned in the order of the presets,
+// without duplicates, and the custom types are merged.
+func ResolvePresets(names ...string) ([]module.AppModuleBasic, CustomTypeRegistry, error) {
+	var modules []module.AppModuleBasic
+	customTypes := CustomTypeRegistry{}
+	for _, name := range names {
+		preset, ok := ModulePresets[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown preset %q, expected one of %s", name, strings.Join(presetNames(), ", "))
+		}
+
+		modules = mergeModules(modules, preset.Modules)
+		customTypes.add(preset.CustomTypeRegistry)
+	}
+
+	return modules, customTypes, nil
+}
+
+// mergeModules appends the modules whose name is not already in modules
+func mergeModules(modules []module.AppModuleBasic, others []module.AppModuleBasic) []module.AppModuleBasic {
+	seen := map[string]bool{}
+	for _, m := range modules {
+		seen[m.Name()] = true
+	}
+
+	for _, m := range others {
+		if seen[m.Name()] {
+			continue
+		}
+		seen[m.Name()] = true
+		modules = append(modules, m)
+	}
+
+	return modules
+}
+
+func presetNames() []string {
+	names := make([]string, 0, len(ModulePresets))
+	for name := range ModulePresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// codecTypes adds the modules and custom types of the presets of the config to the given ones
+func (ccc *ChainClientConfig) codecTypes(modules []module.AppModuleBasic, customTypeRegistry CustomTypeRegistry, customMsgTypeRegistry map[string]sdkTypes.Msg) ([]module.AppModuleBasic, CustomTypeRegistry, error) {
+	presetModules, presetTypes, err := ResolvePresets(ccc.Presets...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	presetTypes.add(customTypeRegistry)
+	return mergeModules(presetModules, modules), mergeCustomTypes(presetTypes, customMsgTypeRegistry), nil
+}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"cosmossdk.io/store/rootmulti"
+	abci "github.com/cometbft/cometbft/abci/types"
+	"github.com/cometbft/cometbft/crypto/merkle"
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+)
+
+var (
+	// ErrProofVerification is returned when a query result does not match its merkle proof, or
+	// when a result cannot be proven at all
+	ErrProofVerification = errors.New("proof verification failed")
+)
+
+// QueryStore reads a raw key of a module store, e.g. the "bank" or "wasm" store, at the given
+// height. A height of 0 reads the latest state.
+//
+// When Config.VerifyProofs is set, the ICS23 proof returned by the node is checked against the
+// app hash of the header of the next block, and any mismatch is returned as ErrProofVerification.
+// The value of a missing key is nil, verified modes then check a proof of absence.
+func (cc *ChainClient) QueryStore(ctx context.Context, storeName string, key []byte, height int64) ([]byte, error) {
+	prove := cc.Config.VerifyProofs
+
+	// The app hash of a block is only known once the next block is committed, so the latest
+	// verifiable state is the one of the block before the latest
+	if err := cc.RequireRPC("store query"); err != nil {
+		return nil, err
+	}
+
+	if prove && height == 0 {
+		var status *coretypes.ResultStatus
+		err := cc.Retry(ctx, func(ctx context.Context) (err error) {
+			status, err = cc.RPCClient.Status(ctx)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		height = status.SyncInfo.LatestBlockHeight - 1
+	}
+
+	res, err := cc.QueryABCI(ctx, abci.RequestQuery{
+		Path:   fmt.Sprintf("/store/%s/key", storeName),
+		Data:   key,
+		Height: height,
+		Prove:  prove,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if prove {
+		if err := cc.verifyProof(ctx, storeName, key, res); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(res.Value) == 0 {
+		return nil, nil
+	}
+
+	return res.Value, nil
+}
+
+// verifyProof checks the proof of an ABCI store query against the app hash committed in the
+// header of the next block
+func (cc *ChainClient) verifyProof(ctx context.Context, storeName string, key []byte, res abci.ResponseQuery) error {
+	if res.ProofOps == nil || len(res.ProofOps.Ops) == 0 {
+		return fmt.Errorf("%w: the node returned no proof for key %X of store %s", ErrProofVerification, key, storeName)
+	}
+
+	appHash, err := cc.appHash(ctx, res.Height+1)
+	if err != nil {
+		return fmt.Errorf("failed to get the app hash of height %d: %w", res.Height+1, err)
+	}
+
+	keyPath := merkle.KeyPath{}.
+		AppendKey([]byte(storeName), merkle.KeyEncodingURL).
+		AppendKey(key, merkle.KeyEncodingURL).
+		String()
+
+	prt := rootmulti.DefaultProofRuntime()
+	if len(res.Value) == 0 {
+		err = prt.VerifyAbsence(res.ProofOps, appHash, keyPath)
+	} else {
+		err = prt.VerifyValue(res.ProofOps, appHash, keyPath, res.Value)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: key %X of store %s at height %d: %w", ErrProofVerification, key, storeName, res.Height, err)
+	}
+
+	return nil
+}
+
+// appHash returns the app hash of the header at the given height, i.e. the state root after the
+// block at height-1
+func (cc *ChainClient) appHash(ctx context.Context, height int64) ([]byte, error) {
+	var header *coretypes.ResultHeader
+	err := cc.Retry(ctx, func(ctx context.Context) (err error) {
+		header, err = cc.RPCClient.Header(ctx, &height)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a light client the app hash is only as trustworthy as the node
+	if err := cc.VerifyHeader(ctx, header.Header); err != nil {
+		return nil, err
+	}
+
+	return header.Header.AppHash, nil
+}
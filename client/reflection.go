@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	reflectionv1 "cosmossdk.io/api/cosmos/reflection/v1"
+	"github.com/RiemaLabs/probe/logger"
+	reflectionv2 "github.com/cosmos/cosmos-sdk/server/grpc/reflection/v2alpha1"
+	sdkTypes "github.com/cosmos/cosmos-sdk/types"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// ReflectionReport is the outcome of RegisterReflectedTypes
+type ReflectionReport struct {
+	// Registered are the type URLs added to the registry as dynamic messages
+	Registered []string
+	// Undecodable are the type URLs the chain reports but probe cannot decode
+	Undecodable []string
+}
+
+// RegisterReflectedTypes completes the codec with the types reported by the reflection services of
+// the chain. The file descriptors of cosmos.reflection.v1 provide the Msg types, and the codec
+// descriptor of cosmos.base.reflection.v2alpha1 the implementations of every interface. Types
+// that are already registered, e.g. by DefaultModuleBasics, are kept.
+//
+// Chains may expose only one of the services, an error is returned when neither answers.
+func (cc *ChainClient) RegisterReflectedTypes(ctx context.Context) (*ReflectionReport, error) {
+	registry := cc.Codec.ProbeInterfaceRegistry
+	report := &ReflectionReport{}
+
+	v1Err := cc.registerReflectedFiles(ctx, report)
+	if v1Err != nil {
+		logger.Warn("Reflection v1 file descriptors unavailable", "error", v1Err.Error())
+	}
+
+	codecRes, v2Err := reflectionv2.NewReflectionServiceClient(cc).GetCodecDescriptor(ctx, &reflectionv2.GetCodecDescriptorRequest{})
+	if v2Err != nil {
+		logger.Warn("Reflection v2alpha1 codec descriptor unavailable", "error", v2Err.Error())
+		if v1Err != nil {
+			return nil, fmt.Errorf("no reflection service answered: %w", v2Err)
+		}
+	}
+
+	undecodable := map[string]bool{}
+	if codecRes != nil && codecRes.Codec != nil {
+		for _, iface := range codecRes.Codec.Interfaces {
+			for _, impl := range iface.InterfaceImplementers {
+				if registry.TypeURLIsRegistered(impl.TypeUrl) {
+					continue
+				}
+
+				if err := registry.RegisterDynamicImplementation(iface.Fullname, impl.TypeUrl); err != nil {
+					logger.Debug("Cannot decode reflected type", "interface", iface.Fullname, "type_url", impl.TypeUrl, "error", err.Error())
+					undecodable[impl.TypeUrl] = true
+					continue
+				}
+				report.Registered = append(report.Registered, impl.TypeUrl)
+			}
+		}
+	}
+
+	// The tx descriptor lists the Msgs of every module, including those without a codec entry
+	txRes, err := reflectionv2.NewReflectionServiceClient(cc).GetTxDescriptor(ctx, &reflectionv2.GetTxDescriptorRequest{})
+	if err == nil && txRes.Tx != nil {
+		for _, msg := range txRes.Tx.Msgs {
+			if !registry.TypeURLIsRegistered(msg.MsgTypeUrl) {
+				undecodable[msg.MsgTypeUrl] = true
+			}
+		}
+	}
+
+	for typeURL := range undecodable {
+		report.Undecodable = append(report.Undecodable, typeURL)
+	}
+	sort.Strings(report.Registered)
+	sort.Strings(report.Undecodable)
+
+	if len(report.Undecodable) > 0 {
+		logger.Warn("Chain types cannot be decoded locally", "type_urls", report.Undecodable)
+	}
+
+	return report, nil
+}
+
+func (cc *ChainClient) registerReflectedFiles(ctx context.Context, report *ReflectionReport) error {
+	res, err := reflectionv1.NewReflectionServiceClient(cc).FileDescriptors(ctx, &reflectionv1.FileDescriptorsRequest{})
+	if err != nil {
+		return err
+	}
+
+	typeURLs, err := cc.Codec.ProbeInterfaceRegistry.RegisterFileDescriptorSet((*sdkTypes.Msg)(nil), &descriptorpb.FileDescriptorSet{File: res.Files})
+	if err != nil {
+		return err
+	}
+
+	report.Registered = append(report.Registered, typeURLs...)
+	return nil
+}
@@ -0,0 +1,261 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	cmtjson "github.com/cometbft/cometbft/libs/json"
+	"github.com/cosmos/gogoproto/proto"
+	"gopkg.in/yaml.v3"
+)
+
+const probePkgPath = "github.com/RiemaLabs/probe/"
+
+// Render renders a probe result in the output format of the config, see Codec.Render
+func (cc *ChainClient) Render(v interface{}) ([]byte, error) {
+	return cc.Codec.Render(v, cc.Config.OutputFormat)
+}
+
+// RenderTo writes a probe result rendered by Render to w, followed by a newline
+func (cc *ChainClient) RenderTo(w io.Writer, v interface{}) error {
+	bz, err := cc.Render(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(bz, '\n'))
+	return err
+}
+
+// Render renders a probe result in one of the OutputFormats, an empty format is json:
+//   - json: compact proto-JSON, indent: indented proto-JSON
+//   - yaml: the proto-JSON document as YAML
+//   - text: a table of the leaves of the document, one "path value" row each
+//
+// Protobuf messages, such as GetTxsEventResponse, are rendered as proto-JSON with the interface
+// registry, so the messages in Any values are rendered with their fields, including dynamic ones.
+// CometBFT results, such as ResultBlock, are rendered as by the CometBFT RPC. Byte slices, such as
+// wasm query results, are rendered as is when they are JSON and base64 encoded otherwise.
+func (c Codec) Render(v interface{}, format string) ([]byte, error) {
+	bz, err := c.renderJSON(reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case "", "json":
+		return bz, nil
+	case "indent":
+		var out bytes.Buffer
+		if err := json.Indent(&out, bz, "", "  "); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	case "yaml":
+		// JSON is YAML, going through a node keeps the order of the keys
+		var node yaml.Node
+		if err := yaml.Unmarshal(bz, &node); err != nil {
+			return nil, err
+		}
+		resetYAMLStyle(&node)
+
+		var out bytes.Buffer
+		enc := yaml.NewEncoder(&out)
+		enc.SetIndent(2)
+		if err := enc.Encode(&node); err != nil {
+			return nil, err
+		}
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+		return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+	case "text":
+		return renderText(bz)
+	default:
+		return nil, fmt.Errorf("unknown output format %q, expected one of %s", format, strings.Join(OutputFormats, ", "))
+	}
+}
+
+var (
+	bytesType   = reflect.TypeOf([]byte(nil))
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	messageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
+)
+
+// renderJSON renders v as compact JSON. The structs of probe are walked so that the protobuf
+// messages they hold are rendered as proto-JSON.
+func (c Codec) renderJSON(v reflect.Value) (json.RawMessage, error) {
+	if !v.IsValid() || (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return json.RawMessage("null"), nil
+	}
+
+	typ := v.Type()
+	switch {
+	case typ.Implements(messageType):
+		return c.Marshaler.MarshalJSON(v.Interface().(proto.Message))
+	case typ.Implements(errorType):
+		return json.Marshal(v.Interface().(error).Error())
+	case typ.Kind() == reflect.Interface:
+		return c.renderJSON(v.Elem())
+	case typ.ConvertibleTo(bytesType) && typ.Kind() == reflect.Slice:
+		bz := v.Convert(bytesType).Interface().([]byte)
+		if json.Valid(bz) {
+			var out bytes.Buffer
+			if err := json.Compact(&out, bz); err != nil {
+				return nil, err
+			}
+			return out.Bytes(), nil
+		}
+		return json.Marshal(bz)
+	case typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array:
+		if !containsProbeOrProto(typ.Elem()) {
+			break
+		}
+		items := make([]json.RawMessage, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			item, err := c.renderJSON(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return json.Marshal(items)
+	case typ.Kind() == reflect.Ptr && typ.Elem().Kind() == reflect.Struct && strings.HasPrefix(typ.Elem().PkgPath(), probePkgPath):
+		return c.renderJSON(v.Elem())
+	case typ.Kind() == reflect.Struct && strings.HasPrefix(typ.PkgPath(), probePkgPath):
+		return c.renderStruct(v)
+	}
+
+	return cmtjson.Marshal(v.Interface())
+}
+
+// renderStruct renders the exported fields of a probe struct, named after their json tag
+func (c Codec) renderStruct(v reflect.Value) (json.RawMessage, error) {
+	var out bytes.Buffer
+	out.WriteByte('{')
+
+	typ := v.Type()
+	first := true
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+
+		value, err := c.renderJSON(v.Field(i))
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		if !first {
+			out.WriteByte(',')
+		}
+		first = false
+
+		key, _ := json.Marshal(name)
+		out.Write(key)
+		out.WriteByte(':')
+		out.Write(value)
+	}
+
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
+
+// containsProbeOrProto reports whether values of typ need renderJSON rather than cmtjson
+func containsProbeOrProto(typ reflect.Type) bool {
+	if typ.Implements(messageType) || typ.Implements(errorType) || typ.Kind() == reflect.Interface {
+		return true
+	}
+	for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.Struct && strings.HasPrefix(typ.PkgPath(), probePkgPath)
+}
+
+// resetYAMLStyle drops the flow style and quotes of the JSON document, the scalars that would be
+// read as another type stay quoted
+func resetYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetYAMLStyle(child)
+	}
+}
+
+// renderText renders a JSON document as a two columns table of its leaves and their path
+func renderText(bz []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(bz))
+	dec.UseNumber()
+
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	w := tabwriter.NewWriter(&out, 0, 4, 2, ' ', 0)
+	writeText(w, "", doc)
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+}
+
+func writeText(w io.Writer, path string, v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			writeRow(w, path, "{}")
+			return
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			writeText(w, joinPath(path, key), v[key])
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			writeRow(w, path, "[]")
+			return
+		}
+		for i, item := range v {
+			writeText(w, joinPath(path, strconv.Itoa(i)), item)
+		}
+	case nil:
+		writeRow(w, path, "null")
+	default:
+		writeRow(w, path, fmt.Sprint(v))
+	}
+}
+
+func writeRow(w io.Writer, path string, value string) {
+	if path == "" {
+		path = "."
+	}
+	fmt.Fprintf(w, "%s\t%s\n", path, value)
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
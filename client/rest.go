@@ -0,0 +1,851 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	cmtbytes "github.com/cometbft/cometbft/libs/bytes"
+	"github.com/cometbft/cometbft/libs/service"
+	"github.com/cometbft/cometbft/p2p"
+	cmtcrypto "github.com/cometbft/cometbft/proto/tendermint/crypto"
+	rpcclient "github.com/cometbft/cometbft/rpc/client"
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+	"github.com/cosmos/cosmos-sdk/client/grpc/cmtservice"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	grpctypes "github.com/cosmos/cosmos-sdk/types/grpc"
+	txTypes "github.com/cosmos/cosmos-sdk/types/tx"
+	gogoproto "github.com/cosmos/gogoproto/proto"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// TransportREST sends every query to the REST gateway of the app API server (usually on port 1317)
+const TransportREST = "rest"
+
+// restPageLimit is the page size used when the REST client needs to walk all txs of a height
+const restPageLimit = 100
+
+var _ rpcclient.Client = &RESTClient{}
+
+// RESTClient is a rpcclient.Client backed by the REST gateway of the app API server.
+//
+// It covers the calls probe makes: status, abci info, blocks, headers, block results, tx search,
+// tx lookups and ABCI queries. gRPC query paths are mapped to their REST routes with the
+// google.api.http annotations of the query service, while store, app and custom paths go through
+// the generic abci_query route. All responses are decoded with the client codec into the same types
+// the CometBFT RPC returns. Calls the API server cannot serve return errors.ErrUnsupported.
+type RESTClient struct {
+	service.BaseService
+
+	addr       string
+	httpClient *http.Client
+	codec      Codec
+}
+
+// NewRESTClient creates a client for the REST gateway at addr, e.g. http://localhost:1317
+func NewRESTClient(addr string, timeout time.Duration, debug bool, cdc Codec) (*RESTClient, error) {
+	if _, err := url.Parse(addr); err != nil {
+		return nil, fmt.Errorf("invalid api address %s: %w", addr, err)
+	}
+
+	httpClient := &http.Client{Timeout: timeout, Transport: http.DefaultTransport}
+	if debug {
+		httpClient.Transport = &loggingRoundTripper{httpClient.Transport}
+	}
+
+	rc := &RESTClient{
+		addr:       strings.TrimSuffix(addr, "/"),
+		httpClient: httpClient,
+		codec:      cdc,
+	}
+	rc.BaseService = *service.NewBaseService(nil, "RESTClient", rc)
+
+	return rc, nil
+}
+
+// restError is the error body returned by the gateway
+type restError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// do sends a request to the gateway and returns the response body. Gateway errors are turned into
+// gRPC status errors so they are classified like the errors of the other transports.
+func (rc *RESTClient) do(ctx context.Context, method, path string, params url.Values, body []byte, height int64) ([]byte, http.Header, error) {
+	u := rc.addr + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if height > 0 {
+		req.Header.Set(grpctypes.GRPCBlockHeightHeader, strconv.FormatInt(height, 10))
+	}
+
+	res, err := rc.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		var restErr restError
+		if err := json.Unmarshal(resBody, &restErr); err == nil && restErr.Message != "" {
+			return nil, nil, status.Error(codes.Code(restErr.Code), restErr.Message)
+		}
+		return nil, nil, fmt.Errorf("rest request %s failed (Status: %d): %s", path, res.StatusCode, string(resBody))
+	}
+
+	return resBody, res.Header, nil
+}
+
+func (rc *RESTClient) get(ctx context.Context, path string, params url.Values, height int64, out gogoproto.Message) error {
+	body, _, err := rc.do(ctx, http.MethodGet, path, params, nil, height)
+	if err != nil {
+		return err
+	}
+	return rc.codec.Marshaler.UnmarshalJSON(body, out)
+}
+
+func restUnsupported(method string) error {
+	return fmt.Errorf("%s is not available on the rest transport: %w", method, errors.ErrUnsupported)
+}
+
+// ABCIClient
+
+func (rc *RESTClient) ABCIInfo(ctx context.Context) (*coretypes.ResultABCIInfo, error) {
+	var nodeInfo cmtservice.GetNodeInfoResponse
+	if err := rc.get(ctx, "/cosmos/base/tendermint/v1beta1/node_info", nil, 0, &nodeInfo); err != nil {
+		return nil, err
+	}
+
+	block, _, err := rc.block(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	info := abci.ResponseInfo{
+		AppVersion:       block.Version.App,
+		LastBlockHeight:  block.Height,
+		LastBlockAppHash: block.AppHash,
+	}
+	if nodeInfo.ApplicationVersion != nil {
+		info.Data = nodeInfo.ApplicationVersion.AppName
+		info.Version = nodeInfo.ApplicationVersion.Version
+	}
+
+	return &coretypes.ResultABCIInfo{Response: info}, nil
+}
+
+func (rc *RESTClient) ABCIQuery(ctx context.Context, path string, data cmtbytes.HexBytes) (*coretypes.ResultABCIQuery, error) {
+	return rc.ABCIQueryWithOptions(ctx, path, data, rpcclient.DefaultABCIQueryOptions)
+}
+
+// ABCIQueryWithOptions runs gRPC query paths on their REST route and every other path on abci_query
+func (rc *RESTClient) ABCIQueryWithOptions(ctx context.Context, path string, data cmtbytes.HexBytes, opts rpcclient.ABCIQueryOptions) (*coretypes.ResultABCIQuery, error) {
+	for _, prefix := range []string{"/store/", "/app/", "/custom/", "/p2p/", "store/", "app/", "custom/", "p2p/"} {
+		if strings.HasPrefix(path, prefix) {
+			return rc.abciQuery(ctx, path, data, opts)
+		}
+	}
+
+	return rc.grpcQuery(ctx, path, data, opts.Height)
+}
+
+func (rc *RESTClient) abciQuery(ctx context.Context, path string, data cmtbytes.HexBytes, opts rpcclient.ABCIQueryOptions) (*coretypes.ResultABCIQuery, error) {
+	params := url.Values{}
+	params.Set("path", path)
+	params.Set("data", base64.StdEncoding.EncodeToString(data))
+	params.Set("height", strconv.FormatInt(opts.Height, 10))
+	params.Set("prove", strconv.FormatBool(opts.Prove))
+
+	var res cmtservice.ABCIQueryResponse
+	if err := rc.get(ctx, "/cosmos/base/tendermint/v1beta1/abci_query", params, 0, &res); err != nil {
+		return nil, err
+	}
+
+	resQuery := abci.ResponseQuery{
+		Code:      res.Code,
+		Log:       res.Log,
+		Info:      res.Info,
+		Index:     res.Index,
+		Key:       res.Key,
+		Value:     res.Value,
+		Height:    res.Height,
+		Codespace: res.Codespace,
+	}
+	if res.ProofOps != nil {
+		resQuery.ProofOps = &cmtcrypto.ProofOps{}
+		for _, op := range res.ProofOps.Ops {
+			resQuery.ProofOps.Ops = append(resQuery.ProofOps.Ops, cmtcrypto.ProofOp{Type: op.Type, Key: op.Key, Data: op.Data})
+		}
+	}
+
+	return &coretypes.ResultABCIQuery{Response: resQuery}, nil
+}
+
+// grpcQuery maps a gRPC query method to its REST route. The binary request is decoded into the
+// request type of the method, its fields fill the route variables and the query string, and the
+// JSON response is decoded into the response type and encoded back to binary.
+func (rc *RESTClient) grpcQuery(ctx context.Context, method string, data []byte, height int64) (*coretypes.ResultABCIQuery, error) {
+	md, rule, err := findHTTPRule(method)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := newGogoMessage(md.Input().FullName())
+	if err != nil {
+		return nil, err
+	}
+	if err := rc.codec.Marshaler.Unmarshal(data, req); err != nil {
+		return nil, fmt.Errorf("failed to decode request of %s: %w", method, err)
+	}
+
+	reqJSON, err := rc.codec.Marshaler.MarshalJSON(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(reqJSON, &fields); err != nil {
+		return nil, err
+	}
+
+	httpMethod, template := httpRulePattern(rule)
+	if template == "" {
+		return nil, fmt.Errorf("method %s has no rest route", method)
+	}
+
+	path, err := expandRESTPath(template, md.Input(), fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build rest route of %s: %w", method, err)
+	}
+
+	var (
+		params url.Values
+		body   []byte
+	)
+	if rule.GetBody() == "*" {
+		body = reqJSON
+	} else {
+		params = url.Values{}
+		addQueryParams(params, "", md.Input(), fields)
+	}
+
+	resBody, header, err := rc.do(ctx, httpMethod, path, params, body, height)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := newGogoMessage(md.Output().FullName())
+	if err != nil {
+		return nil, err
+	}
+	if err := rc.codec.Marshaler.UnmarshalJSON(resBody, res); err != nil {
+		return nil, fmt.Errorf("failed to decode response of %s: %w", method, err)
+	}
+
+	value, err := rc.codec.Marshaler.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+
+	resHeight, _ := strconv.ParseInt(header.Get("Grpc-Metadata-"+grpctypes.GRPCBlockHeightHeader), 10, 64)
+
+	return &coretypes.ResultABCIQuery{Response: abci.ResponseQuery{Value: value, Height: resHeight}}, nil
+}
+
+// findHTTPRule looks up the google.api.http annotation of a gRPC method such as
+// /cosmos.staking.v1beta1.Query/Validators
+func findHTTPRule(method string) (protoreflect.MethodDescriptor, *annotations.HttpRule, error) {
+	name := protoreflect.FullName(strings.ReplaceAll(strings.TrimPrefix(method, "/"), "/", "."))
+	desc, err := gogoproto.HybridResolver.FindDescriptorByName(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unknown query method %s: %w", method, err)
+	}
+
+	md, ok := desc.(protoreflect.MethodDescriptor)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s is not a query method", method)
+	}
+
+	// The options are re-parsed so that the http extension is resolved even if the descriptor
+	// was built before the annotations package registered it
+	bz, err := protov2.Marshal(md.Options())
+	if err != nil {
+		return nil, nil, err
+	}
+	opts := &descriptorpb.MethodOptions{}
+	if err := (protov2.UnmarshalOptions{Resolver: protoregistry.GlobalTypes}).Unmarshal(bz, opts); err != nil {
+		return nil, nil, err
+	}
+
+	rule, ok := protov2.GetExtension(opts, annotations.E_Http).(*annotations.HttpRule)
+	if !ok || rule == nil {
+		return nil, nil, fmt.Errorf("method %s has no rest route", method)
+	}
+
+	return md, rule, nil
+}
+
+func httpRulePattern(rule *annotations.HttpRule) (string, string) {
+	switch pattern := rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		return http.MethodGet, pattern.Get
+	case *annotations.HttpRule_Post:
+		return http.MethodPost, pattern.Post
+	case *annotations.HttpRule_Put:
+		return http.MethodPut, pattern.Put
+	case *annotations.HttpRule_Delete:
+		return http.MethodDelete, pattern.Delete
+	case *annotations.HttpRule_Patch:
+		return http.MethodPatch, pattern.Patch
+	}
+	return "", ""
+}
+
+// expandRESTPath fills the {field} variables of a route template and removes the used fields
+// from the request fields, so that the remaining ones can be sent in the query string
+func expandRESTPath(template string, input protoreflect.MessageDescriptor, fields map[string]interface{}) (string, error) {
+	var sb strings.Builder
+	for {
+		start := strings.Index(template, "{")
+		if start < 0 {
+			sb.WriteString(template)
+			return sb.String(), nil
+		}
+		end := strings.Index(template[start:], "}")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated variable in route %s", template)
+		}
+		end += start
+
+		sb.WriteString(template[:start])
+
+		fieldPath, _, _ := strings.Cut(template[start+1:end], "=")
+		value, fd := popField(fieldPath, input, fields)
+		if value == nil {
+			return "", fmt.Errorf("missing value for %s", fieldPath)
+		}
+
+		str := fmt.Sprint(value)
+		// Bytes are base64 encoded in JSON, the URL safe alphabet keeps them in one path segment.
+		// Some bytes fields, like wasm query messages, are inlined as raw JSON instead.
+		if fd != nil && fd.Kind() == protoreflect.BytesKind {
+			raw, err := jsonBytesValue(value)
+			if err != nil {
+				return "", err
+			}
+			str = base64.URLEncoding.EncodeToString(raw)
+		}
+		sb.WriteString(url.PathEscape(str))
+
+		template = template[end+1:]
+	}
+}
+
+func jsonBytesValue(value interface{}) ([]byte, error) {
+	if str, ok := value.(string); ok {
+		if raw, err := base64.StdEncoding.DecodeString(str); err == nil {
+			return raw, nil
+		}
+	}
+	return json.Marshal(value)
+}
+
+// popField returns and removes the value of a dotted field path from the JSON request fields
+func popField(fieldPath string, input protoreflect.MessageDescriptor, fields map[string]interface{}) (interface{}, protoreflect.FieldDescriptor) {
+	names := strings.Split(fieldPath, ".")
+	var fd protoreflect.FieldDescriptor
+	for i, name := range names {
+		if input != nil {
+			fd = input.Fields().ByName(protoreflect.Name(name))
+			if fd != nil {
+				input = fd.Message()
+			} else {
+				input = nil
+			}
+		}
+
+		value, ok := fields[name]
+		if !ok {
+			return nil, nil
+		}
+		if i == len(names)-1 {
+			delete(fields, name)
+			return value, fd
+		}
+		fields, ok = value.(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+	}
+	return nil, nil
+}
+
+// addQueryParams flattens the request fields into query parameters, e.g. pagination.key.
+// Default values are skipped since the gateway treats missing parameters as defaults.
+func addQueryParams(params url.Values, prefix string, input protoreflect.MessageDescriptor, fields map[string]interface{}) {
+	for name, value := range fields {
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+
+		var fd protoreflect.FieldDescriptor
+		if input != nil {
+			fd = input.Fields().ByName(protoreflect.Name(name))
+		}
+
+		switch v := value.(type) {
+		case nil:
+		case map[string]interface{}:
+			var nested protoreflect.MessageDescriptor
+			if fd != nil {
+				nested = fd.Message()
+			}
+			addQueryParams(params, key, nested, v)
+		case []interface{}:
+			for _, item := range v {
+				params.Add(key, fmt.Sprint(item))
+			}
+		case string:
+			if !isDefaultJSONValue(fd, v) {
+				params.Set(key, v)
+			}
+		case bool:
+			if v {
+				params.Set(key, "true")
+			}
+		case float64:
+			if v != 0 {
+				params.Set(key, strconv.FormatFloat(v, 'f', -1, 64))
+			}
+		default:
+			params.Set(key, fmt.Sprint(v))
+		}
+	}
+}
+
+// isDefaultJSONValue reports whether a JSON string is the default value of its field. 64 bit
+// integers and enums are encoded as strings in JSON.
+func isDefaultJSONValue(fd protoreflect.FieldDescriptor, v string) bool {
+	if v == "" {
+		return true
+	}
+	if fd == nil {
+		return false
+	}
+
+	switch fd.Kind() {
+	case protoreflect.Int64Kind, protoreflect.Uint64Kind, protoreflect.Sint64Kind,
+		protoreflect.Fixed64Kind, protoreflect.Sfixed64Kind:
+		return v == "0"
+	case protoreflect.EnumKind:
+		return fd.Enum().Values().Len() > 0 && v == string(fd.Enum().Values().Get(0).Name())
+	}
+	return false
+}
+
+func newGogoMessage(name protoreflect.FullName) (gogoproto.Message, error) {
+	typ := gogoproto.MessageType(string(name))
+	if typ == nil {
+		return nil, fmt.Errorf("no go type registered for %s", name)
+	}
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	msg, ok := reflect.New(typ).Interface().(gogoproto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a gogoproto message", name)
+	}
+	return msg, nil
+}
+
+func (rc *RESTClient) BroadcastTxCommit(context.Context, cmttypes.Tx) (*coretypes.ResultBroadcastTxCommit, error) {
+	return nil, restUnsupported("BroadcastTxCommit")
+}
+
+func (rc *RESTClient) BroadcastTxAsync(context.Context, cmttypes.Tx) (*coretypes.ResultBroadcastTx, error) {
+	return nil, restUnsupported("BroadcastTxAsync")
+}
+
+func (rc *RESTClient) BroadcastTxSync(context.Context, cmttypes.Tx) (*coretypes.ResultBroadcastTx, error) {
+	return nil, restUnsupported("BroadcastTxSync")
+}
+
+// SignClient
+
+// block fetches the block at height, or the latest block if height is 0
+func (rc *RESTClient) block(ctx context.Context, height int64) (*cmttypes.Block, *cmttypes.BlockID, error) {
+	var res cmtservice.GetBlockByHeightResponse
+	path := "/cosmos/base/tendermint/v1beta1/blocks/latest"
+	if height > 0 {
+		path = fmt.Sprintf("/cosmos/base/tendermint/v1beta1/blocks/%d", height)
+	}
+	if err := rc.get(ctx, path, nil, 0, &res); err != nil {
+		return nil, nil, err
+	}
+
+	if res.Block == nil || res.BlockId == nil {
+		return nil, nil, fmt.Errorf("api server returned no block for height %d", height)
+	}
+
+	block, err := cmttypes.BlockFromProto(res.Block)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	blockID, err := cmttypes.BlockIDFromProto(res.BlockId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return block, blockID, nil
+}
+
+func (rc *RESTClient) Block(ctx context.Context, height *int64) (*coretypes.ResultBlock, error) {
+	block, blockID, err := rc.block(ctx, derefHeight(height))
+	if err != nil {
+		return nil, err
+	}
+	return &coretypes.ResultBlock{BlockID: *blockID, Block: block}, nil
+}
+
+func (rc *RESTClient) BlockByHash(context.Context, []byte) (*coretypes.ResultBlock, error) {
+	return nil, restUnsupported("BlockByHash")
+}
+
+// BlockResults is rebuilt from the txs of the height, so only Height and TxsResults are set. The
+// API server does not expose the finalize block events, the validator updates nor the consensus
+// param updates, FinalizeBlockEvents, ValidatorUpdates and ConsensusParamUpdates are left empty.
+func (rc *RESTClient) BlockResults(ctx context.Context, height *int64) (*coretypes.ResultBlockResults, error) {
+	block, _, err := rc.block(ctx, derefHeight(height))
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := map[int64]*cmttypes.Block{block.Height: block}
+	results := &coretypes.ResultBlockResults{Height: block.Height, TxsResults: make([]*abci.ExecTxResult, len(block.Txs))}
+	found := 0
+	for page := 1; found < len(block.Txs); page++ {
+		limit := restPageLimit
+		res, err := rc.txSearch(ctx, fmt.Sprintf("tx.height=%d", block.Height), &page, &limit, "asc", blocks)
+		if err != nil {
+			return nil, err
+		}
+		if len(res.Txs) == 0 {
+			break
+		}
+
+		for _, tx := range res.Txs {
+			if results.TxsResults[tx.Index] == nil {
+				found++
+			}
+			txResult := tx.TxResult
+			results.TxsResults[tx.Index] = &txResult
+		}
+	}
+
+	if found != len(block.Txs) {
+		return nil, fmt.Errorf("api server returned %d tx results for the %d txs of block %d", found, len(block.Txs), block.Height)
+	}
+	return results, nil
+}
+
+func (rc *RESTClient) Header(ctx context.Context, height *int64) (*coretypes.ResultHeader, error) {
+	block, _, err := rc.block(ctx, derefHeight(height))
+	if err != nil {
+		return nil, err
+	}
+	return &coretypes.ResultHeader{Header: &block.Header}, nil
+}
+
+func (rc *RESTClient) HeaderByHash(context.Context, cmtbytes.HexBytes) (*coretypes.ResultHeader, error) {
+	return nil, restUnsupported("HeaderByHash")
+}
+
+func (rc *RESTClient) Commit(context.Context, *int64) (*coretypes.ResultCommit, error) {
+	return nil, restUnsupported("Commit")
+}
+
+func (rc *RESTClient) Validators(context.Context, *int64, *int, *int) (*coretypes.ResultValidators, error) {
+	return nil, restUnsupported("Validators")
+}
+
+func (rc *RESTClient) Tx(ctx context.Context, hash []byte, prove bool) (*coretypes.ResultTx, error) {
+	if prove {
+		return nil, restUnsupported("Tx with proof")
+	}
+
+	var res txTypes.GetTxResponse
+	if err := rc.get(ctx, "/cosmos/tx/v1beta1/txs/"+hex.EncodeToString(hash), nil, 0, &res); err != nil {
+		return nil, err
+	}
+
+	return rc.resultTx(ctx, res.TxResponse, map[int64]*cmttypes.Block{})
+}
+
+func (rc *RESTClient) TxSearch(ctx context.Context, query string, prove bool, page, perPage *int, orderBy string) (*coretypes.ResultTxSearch, error) {
+	if prove {
+		return nil, restUnsupported("TxSearch with proof")
+	}
+
+	return rc.txSearch(ctx, query, page, perPage, orderBy, map[int64]*cmttypes.Block{})
+}
+
+// txSearch searches txs through the API server, blocks caches the blocks the raw txs are read from
+func (rc *RESTClient) txSearch(ctx context.Context, query string, page, perPage *int, orderBy string, blocks map[int64]*cmttypes.Block) (*coretypes.ResultTxSearch, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	if page != nil {
+		params.Set("page", strconv.Itoa(*page))
+	}
+	if perPage != nil {
+		params.Set("limit", strconv.Itoa(*perPage))
+	}
+	switch orderBy {
+	case "asc":
+		params.Set("order_by", txTypes.OrderBy_ORDER_BY_ASC.String())
+	case "desc":
+		params.Set("order_by", txTypes.OrderBy_ORDER_BY_DESC.String())
+	}
+
+	var res txTypes.GetTxsEventResponse
+	if err := rc.get(ctx, "/cosmos/tx/v1beta1/txs", params, 0, &res); err != nil {
+		return nil, err
+	}
+
+	if len(res.Txs) != len(res.TxResponses) {
+		return nil, fmt.Errorf("api server returned %d txs for %d tx responses", len(res.Txs), len(res.TxResponses))
+	}
+
+	result := &coretypes.ResultTxSearch{TotalCount: int(res.Total)}
+	for _, txResp := range res.TxResponses {
+		resultTx, err := rc.resultTx(ctx, txResp, blocks)
+		if err != nil {
+			return nil, err
+		}
+		result.Txs = append(result.Txs, resultTx)
+	}
+
+	return result, nil
+}
+
+// resultTx converts a tx response of the API server to the CometBFT RPC type. The API server only
+// returns decoded txs, the raw tx and its index are read from the block, see blockTx.
+func (rc *RESTClient) resultTx(ctx context.Context, txResp *sdk.TxResponse, blocks map[int64]*cmttypes.Block) (*coretypes.ResultTx, error) {
+	if txResp == nil {
+		return nil, fmt.Errorf("api server returned an empty tx")
+	}
+
+	hash, err := hex.DecodeString(txResp.TxHash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tx hash %s: %w", txResp.TxHash, err)
+	}
+
+	data, err := hex.DecodeString(txResp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tx data of %s: %w", txResp.TxHash, err)
+	}
+
+	txBytes, index, err := rc.blockTx(ctx, txResp.Height, hash, blocks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get raw tx %s: %w", txResp.TxHash, err)
+	}
+
+	return &coretypes.ResultTx{
+		Hash:   hash,
+		Height: txResp.Height,
+		Index:  index,
+		TxResult: abci.ExecTxResult{
+			Code:      txResp.Code,
+			Data:      data,
+			Log:       txResp.RawLog,
+			Info:      txResp.Info,
+			GasWanted: txResp.GasWanted,
+			GasUsed:   txResp.GasUsed,
+			Events:    txResp.Events,
+			Codespace: txResp.Codespace,
+		},
+		Tx: txBytes,
+	}, nil
+}
+
+// blockTx returns the raw bytes of the tx with the given hash and its index in the block at height.
+// Encoding the decoded tx again would not give back the bytes of a non canonically encoded tx, nor
+// its index. blocks caches the fetched blocks.
+func (rc *RESTClient) blockTx(ctx context.Context, height int64, hash []byte, blocks map[int64]*cmttypes.Block) (cmttypes.Tx, uint32, error) {
+	block, ok := blocks[height]
+	if !ok {
+		var err error
+		block, _, err = rc.block(ctx, height)
+		if err != nil {
+			return nil, 0, err
+		}
+		blocks[height] = block
+	}
+
+	for i, blockTx := range block.Txs {
+		if bytes.Equal(blockTx.Hash(), hash) {
+			return blockTx, uint32(i), nil
+		}
+	}
+	return nil, 0, fmt.Errorf("tx is not in the block at height %d", height)
+}
+
+func (rc *RESTClient) BlockSearch(context.Context, string, *int, *int, string) (*coretypes.ResultBlockSearch, error) {
+	return nil, restUnsupported("BlockSearch")
+}
+
+// HistoryClient
+
+func (rc *RESTClient) Genesis(context.Context) (*coretypes.ResultGenesis, error) {
+	return nil, restUnsupported("Genesis")
+}
+
+func (rc *RESTClient) GenesisChunked(context.Context, uint) (*coretypes.ResultGenesisChunk, error) {
+	return nil, restUnsupported("GenesisChunked")
+}
+
+func (rc *RESTClient) BlockchainInfo(context.Context, int64, int64) (*coretypes.ResultBlockchainInfo, error) {
+	return nil, restUnsupported("BlockchainInfo")
+}
+
+// StatusClient
+
+// Status is rebuilt from the node info, syncing and latest block routes. The validator info
+// and the earliest block are not exposed by the API server.
+func (rc *RESTClient) Status(ctx context.Context) (*coretypes.ResultStatus, error) {
+	var nodeInfo cmtservice.GetNodeInfoResponse
+	if err := rc.get(ctx, "/cosmos/base/tendermint/v1beta1/node_info", nil, 0, &nodeInfo); err != nil {
+		return nil, err
+	}
+
+	var syncing cmtservice.GetSyncingResponse
+	if err := rc.get(ctx, "/cosmos/base/tendermint/v1beta1/syncing", nil, 0, &syncing); err != nil {
+		return nil, err
+	}
+
+	block, blockID, err := rc.block(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var defaultNodeInfo p2p.DefaultNodeInfo
+	if nodeInfo.DefaultNodeInfo != nil {
+		defaultNodeInfo, err = p2p.DefaultNodeInfoFromToProto(nodeInfo.DefaultNodeInfo)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &coretypes.ResultStatus{
+		NodeInfo: defaultNodeInfo,
+		SyncInfo: coretypes.SyncInfo{
+			LatestBlockHash:   blockID.Hash,
+			LatestAppHash:     block.AppHash,
+			LatestBlockHeight: block.Height,
+			LatestBlockTime:   block.Time,
+			CatchingUp:        syncing.Syncing,
+		},
+	}, nil
+}
+
+// NetworkClient
+
+func (rc *RESTClient) NetInfo(context.Context) (*coretypes.ResultNetInfo, error) {
+	return nil, restUnsupported("NetInfo")
+}
+
+func (rc *RESTClient) DumpConsensusState(context.Context) (*coretypes.ResultDumpConsensusState, error) {
+	return nil, restUnsupported("DumpConsensusState")
+}
+
+func (rc *RESTClient) ConsensusState(context.Context) (*coretypes.ResultConsensusState, error) {
+	return nil, restUnsupported("ConsensusState")
+}
+
+func (rc *RESTClient) ConsensusParams(context.Context, *int64) (*coretypes.ResultConsensusParams, error) {
+	return nil, restUnsupported("ConsensusParams")
+}
+
+func (rc *RESTClient) Health(ctx context.Context) (*coretypes.ResultHealth, error) {
+	var nodeInfo cmtservice.GetNodeInfoResponse
+	if err := rc.get(ctx, "/cosmos/base/tendermint/v1beta1/node_info", nil, 0, &nodeInfo); err != nil {
+		return nil, err
+	}
+	return &coretypes.ResultHealth{}, nil
+}
+
+// EventsClient
+
+func (rc *RESTClient) Subscribe(context.Context, string, string, ...int) (<-chan coretypes.ResultEvent, error) {
+	return nil, restUnsupported("Subscribe")
+}
+
+func (rc *RESTClient) Unsubscribe(context.Context, string, string) error {
+	return restUnsupported("Unsubscribe")
+}
+
+func (rc *RESTClient) UnsubscribeAll(context.Context, string) error {
+	return restUnsupported("UnsubscribeAll")
+}
+
+// MempoolClient
+
+func (rc *RESTClient) UnconfirmedTxs(context.Context, *int) (*coretypes.ResultUnconfirmedTxs, error) {
+	return nil, restUnsupported("UnconfirmedTxs")
+}
+
+func (rc *RESTClient) NumUnconfirmedTxs(context.Context) (*coretypes.ResultUnconfirmedTxs, error) {
+	return nil, restUnsupported("NumUnconfirmedTxs")
+}
+
+func (rc *RESTClient) CheckTx(context.Context, cmttypes.Tx) (*coretypes.ResultCheckTx, error) {
+	return nil, restUnsupported("CheckTx")
+}
+
+// EvidenceClient
+
+func (rc *RESTClient) BroadcastEvidence(context.Context, cmttypes.Evidence) (*coretypes.ResultBroadcastEvidence, error) {
+	return nil, restUnsupported("BroadcastEvidence")
+}
+
+func derefHeight(height *int64) int64 {
+	if height == nil {
+		return 0
+	}
+	return *height
+}
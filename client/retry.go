@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/RiemaLabs/probe/logger"
+)
+
+const (
+	DefaultRetryInitialBackoff = 200 * time.Millisecond
+	DefaultRetryMaxBackoff     = 10 * time.Second
+	DefaultRetryMultiplier     = 2.0
+)
+
+// RetryPolicy configures how transient failures of RPC calls are retried.
+// Durations use the same format as ChainClientConfig.Timeout.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one. 0 or 1 disables retries.
+	MaxAttempts int `json:"max-attempts" yaml:"max-attempts"`
+	// InitialBackoff is the wait before the first retry, defaults to DefaultRetryInitialBackoff
+	InitialBackoff string `json:"initial-backoff" yaml:"initial-backoff"`
+	// MaxBackoff caps the wait between two attempts, defaults to DefaultRetryMaxBackoff
+	MaxBackoff string `json:"max-backoff" yaml:"max-backoff"`
+	// Multiplier is applied to the backoff after every attempt, defaults to DefaultRetryMultiplier
+	Multiplier float64 `json:"multiplier" yaml:"multiplier"`
+	// Jitter is the fraction of the backoff that is randomized, between 0 and 1
+	Jitter float64 `json:"jitter" yaml:"jitter"`
+	// RetryHeightNotAvailable also retries the queries of heights the node has not reached yet,
+	// for callers that query the next blocks
+	RetryHeightNotAvailable bool `json:"retry-height-not-available" yaml:"retry-height-not-available"`
+}
+
+// retryable reports whether a failed attempt is worth another one under the policy
+func (rp *RetryPolicy) retryable(err error) bool {
+	return IsTransient(err) || (rp != nil && rp.RetryHeightNotAvailable && errors.Is(err, ErrHeightNotAvailable))
+}
+
+// Backoff returns the wait before the given retry, starting at 1 for the first retry
+func (rp *RetryPolicy) Backoff(retry int) time.Duration {
+	initial, err := time.ParseDuration(rp.InitialBackoff)
+	if err != nil || initial <= 0 {
+		initial = DefaultRetryInitialBackoff
+	}
+
+	maxBackoff, err := time.ParseDuration(rp.MaxBackoff)
+	if err != nil || maxBackoff <= 0 {
+		maxBackoff = DefaultRetryMaxBackoff
+	}
+
+	multiplier := rp.Multiplier
+	if multiplier < 1 {
+		multiplier = DefaultRetryMultiplier
+	}
+
+	backoff := float64(initial)
+	for i := 1; i < retry && backoff < float64(maxBackoff); i++ {
+		backoff *= multiplier
+	}
+	if backoff > float64(maxBackoff) {
+		backoff = float64(maxBackoff)
+	}
+
+	if rp.Jitter > 0 {
+		jitter := rp.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		// Spread the backoff uniformly over [backoff*(1-jitter), backoff*(1+jitter)]
+		backoff += backoff * jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(backoff)
+}
+
+// Retry runs op until it succeeds, fails with a non-transient error, the context is done or the
+// configured retry policy is exhausted. Heights the node has not reached yet are only retried with
+// RetryPolicy.RetryHeightNotAvailable. Every attempt gets its own context bounded by the configured
+// timeout, so that a single slow answer is retried, the whole call is only bounded by ctx. The
+// returned error is classified, see ClassifyError.
+func (cc *ChainClient) Retry(ctx context.Context, op func(ctx context.Context) error) error {
+	maxAttempts := 1
+	policy := cc.Config.Retry
+	if policy != nil && policy.MaxAttempts > 1 {
+		maxAttempts = policy.MaxAttempts
+	}
+
+	// NewChainClient validates the timeout, a config changed afterwards runs without one rather
+	// than with one that is already expired
+	timeout, err := time.ParseDuration(cc.Config.Timeout)
+	if err != nil {
+		timeout = 0
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = ClassifyError(cc.attempt(ctx, timeout, op))
+		if err == nil || attempt >= maxAttempts || !policy.retryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		backoff := policy.Backoff(attempt)
+		logger.Debug("Retrying transient failure", "attempt", attempt, "backoff", backoff.String(), "error", err.Error())
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+func (cc *ChainClient) attempt(ctx context.Context, timeout time.Duration, op func(ctx context.Context) error) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return op(ctx)
+}
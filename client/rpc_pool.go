@@ -0,0 +1,626 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/RiemaLabs/probe/logger"
+	"github.com/cometbft/cometbft/libs/bytes"
+	"github.com/cometbft/cometbft/libs/service"
+	rpcclient "github.com/cometbft/cometbft/rpc/client"
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+)
+
+const (
+	// DefaultHealthCheckInterval is used by the RPC pool when no interval is configured
+	DefaultHealthCheckInterval = 10 * time.Second
+
+	// DefaultMaxHeightLag is the number of blocks a node may trail the highest node in the pool before it is considered unhealthy
+	DefaultMaxHeightLag = 5
+)
+
+var _ rpcclient.Client = &RPCPool{}
+
+// RPCPool is a rpcclient.Client that spreads calls over several CometBFT RPC nodes.
+//
+// Every node is health-checked in the background with /status. A node is healthy if the
+// call succeeds, the node is not catching up and its latest height is within DefaultMaxHeightLag
+// of the highest node in the pool. Calls are routed round-robin to healthy nodes and fail
+// over to the next node when a call fails with a transient error, see IsTransient, or when the
+// node has not reached or has pruned the height, as the other nodes may lag less or keep more
+// history. Broadcasts
+// are sent to a single node so that a tx is never broadcast twice. A node found serving another network than the
+// configured chain id never serves a call again.
+type RPCPool struct {
+	service.BaseService
+
+	nodes        []*poolNode
+	chainID      string
+	interval     time.Duration
+	maxHeightLag int64
+	next         atomic.Uint64
+
+	subscriptionsMtx sync.Mutex
+	subscriptions    map[poolSubscription]*poolNode
+
+	// ctx is cancelled when the pool stops, stopped is then set so that no check starts anymore
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stopMtx sync.Mutex
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+// poolSubscription identifies a subscription made through the pool
+type poolSubscription struct {
+	subscriber string
+	query      string
+}
+
+type poolNode struct {
+	addr     string
+	client   rpcclient.Client
+	checking atomic.Bool
+
+	mtx          sync.RWMutex
+	healthy      bool
+	mismatch     error
+	catchingUp   bool
+	latestHeight int64
+	lastErr      error
+	checkedAt    time.Time
+}
+
+// PoolNodeStatus is a snapshot of the health of a single node of an RPCPool
+type PoolNodeStatus struct {
+	Addr         string
+	Healthy      bool
+	CatchingUp   bool
+	LatestHeight int64
+	LastError    error
+	CheckedAt    time.Time
+}
+
+// NewRPCPool creates a pool over the given RPC addresses. The pool runs one health check
+// round before returning and keeps checking every interval once started. When chainID is set,
+// nodes serving another network fail their health check.
+func NewRPCPool(addrs []string, chainID string, timeout time.Duration, debug bool, interval time.Duration) (*RPCPool, error) {
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("rpc pool requires at least one address")
+	}
+
+	if interval <= 0 {
+		interval = DefaultHealthCheckInterval
+	}
+
+	pool := &RPCPool{
+		chainID:       chainID,
+		interval:      interval,
+		maxHeightLag:  DefaultMaxHeightLag,
+		subscriptions: map[poolSubscription]*poolNode{},
+	}
+	pool.BaseService = *service.NewBaseService(nil, "RPCPool", pool)
+	pool.ctx, pool.cancel = context.WithCancel(context.Background())
+
+	// The websocket of each node is started by its health check, so that a node which is
+	// down when the pool is created can join the rotation later
+	for _, addr := range addrs {
+		rpcClient, err := newRPCHTTPClient(addr, timeout, debug)
+		if err != nil {
+			return nil, fmt.Errorf("error creating rpc client for %s: %w", addr, err)
+		}
+		pool.nodes = append(pool.nodes, &poolNode{addr: addr, client: rpcClient})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	pool.checkAll(ctx)
+
+	return pool, nil
+}
+
+// OnStart implements service.Service by starting the background health checks
+func (p *RPCPool) OnStart() error {
+	p.wg.Add(1)
+	go p.healthLoop(p.ctx)
+
+	return nil
+}
+
+// OnStop implements service.Service by stopping the health checks, including the re-checks of
+// failed nodes, and the node clients
+func (p *RPCPool) OnStop() {
+	p.stopMtx.Lock()
+	p.stopped = true
+	p.cancel()
+	p.stopMtx.Unlock()
+	p.wg.Wait()
+
+	for _, node := range p.nodes {
+		if node.client.IsRunning() {
+			if err := node.client.Stop(); err != nil {
+				logger.Warn("Failed to stop rpc client", "addr", node.addr, "error", err.Error())
+			}
+		}
+	}
+}
+
+// Nodes returns the current health of every node in the pool
+func (p *RPCPool) Nodes() []PoolNodeStatus {
+	statuses := make([]PoolNodeStatus, 0, len(p.nodes))
+	for _, node := range p.nodes {
+		node.mtx.RLock()
+		statuses = append(statuses, PoolNodeStatus{
+			Addr:         node.addr,
+			Healthy:      node.healthy,
+			CatchingUp:   node.catchingUp,
+			LatestHeight: node.latestHeight,
+			LastError:    node.lastErr,
+			CheckedAt:    node.checkedAt,
+		})
+		node.mtx.RUnlock()
+	}
+	return statuses
+}
+
+func (p *RPCPool) healthLoop(ctx context.Context) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			checkCtx, cancel := context.WithTimeout(ctx, p.interval)
+			p.checkAll(checkCtx)
+			cancel()
+		}
+	}
+}
+
+// checkAll queries /status on every node concurrently and then marks nodes that trail
+// the highest one by more than maxHeightLag as unhealthy
+func (p *RPCPool) checkAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, node := range p.nodes {
+		wg.Add(1)
+		go func(node *poolNode) {
+			defer wg.Done()
+			p.check(ctx, node)
+		}(node)
+	}
+	wg.Wait()
+
+	var maxHeight int64
+	for _, node := range p.nodes {
+		node.mtx.RLock()
+		if node.lastErr == nil && node.latestHeight > maxHeight {
+			maxHeight = node.latestHeight
+		}
+		node.mtx.RUnlock()
+	}
+
+	for _, node := range p.nodes {
+		node.mtx.Lock()
+		node.healthy = node.lastErr == nil && !node.catchingUp && maxHeight-node.latestHeight <= p.maxHeightLag
+		node.mtx.Unlock()
+	}
+}
+
+// check queries /status on the node. A node serving another network than the configured chain
+// id is excluded from the pool for good.
+func (p *RPCPool) check(ctx context.Context, n *poolNode) {
+	status, err := n.client.Status(ctx)
+	mismatch := err == nil && p.chainID != "" && status.NodeInfo.Network != p.chainID
+	if mismatch {
+		err = chainIDMismatch(n.addr, status.NodeInfo.Network, p.chainID)
+	}
+
+	n.mtx.Lock()
+	n.checkedAt = time.Now()
+	n.lastErr = err
+	if mismatch {
+		n.mismatch = err
+	}
+	if err != nil {
+		n.healthy = false
+	} else {
+		n.catchingUp = status.SyncInfo.CatchingUp
+		n.latestHeight = status.SyncInfo.LatestBlockHeight
+	}
+	n.mtx.Unlock()
+
+	if err != nil {
+		logger.Warn("RPC node failed health check", "addr", n.addr, "error", err.Error())
+		return
+	}
+
+	if !n.client.IsRunning() {
+		if err := n.client.Start(); err != nil {
+			logger.Warn("Failed to start rpc websocket", "addr", n.addr, "error", err.Error())
+		}
+	}
+}
+
+// state returns whether the node is healthy and the chain id mismatch found on it, if any
+func (n *poolNode) state() (bool, error) {
+	n.mtx.RLock()
+	defer n.mtx.RUnlock()
+	return n.healthy, n.mismatch
+}
+
+// candidates returns the nodes to try for a call, healthy nodes first in round-robin
+// order followed by the unhealthy ones as a last resort. Nodes serving another network are
+// never candidates, when no other node is left the chain id mismatches are returned.
+func (p *RPCPool) candidates() ([]*poolNode, error) {
+	start := int(p.next.Add(1) % uint64(len(p.nodes)))
+
+	healthy := make([]*poolNode, 0, len(p.nodes))
+	unhealthy := make([]*poolNode, 0, len(p.nodes))
+	var mismatches []error
+	for i := range p.nodes {
+		node := p.nodes[(start+i)%len(p.nodes)]
+		switch isHealthy, mismatch := node.state(); {
+		case mismatch != nil:
+			mismatches = append(mismatches, mismatch)
+		case isHealthy:
+			healthy = append(healthy, node)
+		default:
+			unhealthy = append(unhealthy, node)
+		}
+	}
+
+	if len(healthy)+len(unhealthy) == 0 {
+		return nil, errors.Join(mismatches...)
+	}
+
+	return append(healthy, unhealthy...), nil
+}
+
+// recheck checks the node in the background, unless a check of the node is already running or
+// the pool is stopped
+func (p *RPCPool) recheck(node *poolNode) {
+	p.stopMtx.Lock()
+	defer p.stopMtx.Unlock()
+	if p.stopped || !node.checking.CompareAndSwap(false, true) {
+		return
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer node.checking.Store(false)
+
+		ctx, cancel := context.WithTimeout(p.ctx, p.interval)
+		defer cancel()
+		p.check(ctx, node)
+	}()
+}
+
+// failover reports whether a call that failed on a node may succeed on another one
+func failover(err error) bool {
+	return IsTransient(err) || errors.Is(err, ErrHeightNotAvailable) || errors.Is(err, ErrPruned)
+}
+
+// poolCall runs fn against the pool nodes until one succeeds or fails with an error that other
+// nodes would return the same way, see failover. A node failing with a transient error is
+// re-checked in the background so that it leaves the rotation if it is down.
+func poolCall[T any](ctx context.Context, p *RPCPool, fn func(rpcclient.Client) (T, error)) (T, error) {
+	_, res, err := poolCallNode(ctx, p, fn)
+	return res, err
+}
+
+func poolCallNode[T any](ctx context.Context, p *RPCPool, fn func(rpcclient.Client) (T, error)) (*poolNode, T, error) {
+	var (
+		zero T
+		errs []error
+	)
+
+	candidates, err := p.candidates()
+	if err != nil {
+		return nil, zero, err
+	}
+
+	for _, node := range candidates {
+		res, err := fn(node.client)
+		if err == nil {
+			return node, res, nil
+		}
+
+		err = ClassifyError(err)
+		errs = append(errs, fmt.Errorf("%s: %w", node.addr, err))
+
+		// The caller gave up, there is no point in trying the other nodes
+		if ctx.Err() != nil || !failover(err) {
+			break
+		}
+
+		logger.Debug("RPC call failed, failing over", "addr", node.addr, "error", err.Error())
+		if IsTransient(err) {
+			p.recheck(node)
+		}
+	}
+
+	return nil, zero, errors.Join(errs...)
+}
+
+// poolBroadcast sends a broadcast to the first candidate node only. Failing over could
+// broadcast the tx a second time when the first node received it but failed to answer.
+func poolBroadcast[T any](ctx context.Context, p *RPCPool, fn func(rpcclient.Client) (T, error)) (T, error) {
+	var zero T
+
+	candidates, err := p.candidates()
+	if err != nil {
+		return zero, err
+	}
+
+	node := candidates[0]
+	res, err := fn(node.client)
+	if err != nil {
+		err = ClassifyError(err)
+		if IsTransient(err) {
+			p.recheck(node)
+		}
+		return zero, fmt.Errorf("%s: %w", node.addr, err)
+	}
+
+	return res, nil
+}
+
+// ABCIClient
+
+func (p *RPCPool) ABCIInfo(ctx context.Context) (*coretypes.ResultABCIInfo, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultABCIInfo, error) {
+		return c.ABCIInfo(ctx)
+	})
+}
+
+func (p *RPCPool) ABCIQuery(ctx context.Context, path string, data bytes.HexBytes) (*coretypes.ResultABCIQuery, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultABCIQuery, error) {
+		return c.ABCIQuery(ctx, path, data)
+	})
+}
+
+func (p *RPCPool) ABCIQueryWithOptions(ctx context.Context, path string, data bytes.HexBytes, opts rpcclient.ABCIQueryOptions) (*coretypes.ResultABCIQuery, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultABCIQuery, error) {
+		return c.ABCIQueryWithOptions(ctx, path, data, opts)
+	})
+}
+
+func (p *RPCPool) BroadcastTxCommit(ctx context.Context, tx cmttypes.Tx) (*coretypes.ResultBroadcastTxCommit, error) {
+	return poolBroadcast(ctx, p, func(c rpcclient.Client) (*coretypes.ResultBroadcastTxCommit, error) {
+		return c.BroadcastTxCommit(ctx, tx)
+	})
+}
+
+func (p *RPCPool) BroadcastTxAsync(ctx context.Context, tx cmttypes.Tx) (*coretypes.ResultBroadcastTx, error) {
+	return poolBroadcast(ctx, p, func(c rpcclient.Client) (*coretypes.ResultBroadcastTx, error) {
+		return c.BroadcastTxAsync(ctx, tx)
+	})
+}
+
+func (p *RPCPool) BroadcastTxSync(ctx context.Context, tx cmttypes.Tx) (*coretypes.ResultBroadcastTx, error) {
+	return poolBroadcast(ctx, p, func(c rpcclient.Client) (*coretypes.ResultBroadcastTx, error) {
+		return c.BroadcastTxSync(ctx, tx)
+	})
+}
+
+// SignClient
+
+func (p *RPCPool) Block(ctx context.Context, height *int64) (*coretypes.ResultBlock, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultBlock, error) {
+		return c.Block(ctx, height)
+	})
+}
+
+func (p *RPCPool) BlockByHash(ctx context.Context, hash []byte) (*coretypes.ResultBlock, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultBlock, error) {
+		return c.BlockByHash(ctx, hash)
+	})
+}
+
+func (p *RPCPool) BlockResults(ctx context.Context, height *int64) (*coretypes.ResultBlockResults, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultBlockResults, error) {
+		return c.BlockResults(ctx, height)
+	})
+}
+
+func (p *RPCPool) Header(ctx context.Context, height *int64) (*coretypes.ResultHeader, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultHeader, error) {
+		return c.Header(ctx, height)
+	})
+}
+
+func (p *RPCPool) HeaderByHash(ctx context.Context, hash bytes.HexBytes) (*coretypes.ResultHeader, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultHeader, error) {
+		return c.HeaderByHash(ctx, hash)
+	})
+}
+
+func (p *RPCPool) Commit(ctx context.Context, height *int64) (*coretypes.ResultCommit, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultCommit, error) {
+		return c.Commit(ctx, height)
+	})
+}
+
+func (p *RPCPool) Validators(ctx context.Context, height *int64, page, perPage *int) (*coretypes.ResultValidators, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultValidators, error) {
+		return c.Validators(ctx, height, page, perPage)
+	})
+}
+
+func (p *RPCPool) Tx(ctx context.Context, hash []byte, prove bool) (*coretypes.ResultTx, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultTx, error) {
+		return c.Tx(ctx, hash, prove)
+	})
+}
+
+func (p *RPCPool) TxSearch(ctx context.Context, query string, prove bool, page, perPage *int, orderBy string) (*coretypes.ResultTxSearch, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultTxSearch, error) {
+		return c.TxSearch(ctx, query, prove, page, perPage, orderBy)
+	})
+}
+
+func (p *RPCPool) BlockSearch(ctx context.Context, query string, page, perPage *int, orderBy string) (*coretypes.ResultBlockSearch, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultBlockSearch, error) {
+		return c.BlockSearch(ctx, query, page, perPage, orderBy)
+	})
+}
+
+// HistoryClient
+
+func (p *RPCPool) Genesis(ctx context.Context) (*coretypes.ResultGenesis, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultGenesis, error) {
+		return c.Genesis(ctx)
+	})
+}
+
+func (p *RPCPool) GenesisChunked(ctx context.Context, id uint) (*coretypes.ResultGenesisChunk, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultGenesisChunk, error) {
+		return c.GenesisChunked(ctx, id)
+	})
+}
+
+func (p *RPCPool) BlockchainInfo(ctx context.Context, minHeight, maxHeight int64) (*coretypes.ResultBlockchainInfo, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultBlockchainInfo, error) {
+		return c.BlockchainInfo(ctx, minHeight, maxHeight)
+	})
+}
+
+// StatusClient
+
+func (p *RPCPool) Status(ctx context.Context) (*coretypes.ResultStatus, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultStatus, error) {
+		return c.Status(ctx)
+	})
+}
+
+// NetworkClient
+
+func (p *RPCPool) NetInfo(ctx context.Context) (*coretypes.ResultNetInfo, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultNetInfo, error) {
+		return c.NetInfo(ctx)
+	})
+}
+
+func (p *RPCPool) DumpConsensusState(ctx context.Context) (*coretypes.ResultDumpConsensusState, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultDumpConsensusState, error) {
+		return c.DumpConsensusState(ctx)
+	})
+}
+
+func (p *RPCPool) ConsensusState(ctx context.Context) (*coretypes.ResultConsensusState, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultConsensusState, error) {
+		return c.ConsensusState(ctx)
+	})
+}
+
+func (p *RPCPool) ConsensusParams(ctx context.Context, height *int64) (*coretypes.ResultConsensusParams, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultConsensusParams, error) {
+		return c.ConsensusParams(ctx, height)
+	})
+}
+
+func (p *RPCPool) Health(ctx context.Context) (*coretypes.ResultHealth, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultHealth, error) {
+		return c.Health(ctx)
+	})
+}
+
+// EventsClient
+//
+// A subscription lives on the websocket of a single node, so the pool remembers which
+// node served each subscription of a subscriber in order to route the matching unsubscribe calls.
+
+func (p *RPCPool) Subscribe(ctx context.Context, subscriber, query string, outCapacity ...int) (<-chan coretypes.ResultEvent, error) {
+	node, out, err := poolCallNode(ctx, p, func(c rpcclient.Client) (<-chan coretypes.ResultEvent, error) {
+		return c.Subscribe(ctx, subscriber, query, outCapacity...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p.subscriptionsMtx.Lock()
+	p.subscriptions[poolSubscription{subscriber: subscriber, query: query}] = node
+	p.subscriptionsMtx.Unlock()
+
+	return out, nil
+}
+
+func (p *RPCPool) Unsubscribe(ctx context.Context, subscriber, query string) error {
+	key := poolSubscription{subscriber: subscriber, query: query}
+
+	p.subscriptionsMtx.Lock()
+	node, ok := p.subscriptions[key]
+	delete(p.subscriptions, key)
+	p.subscriptionsMtx.Unlock()
+	if !ok {
+		return fmt.Errorf("subscriber %s has no subscription to %q in the rpc pool", subscriber, query)
+	}
+
+	return node.client.Unsubscribe(ctx, subscriber, query)
+}
+
+func (p *RPCPool) UnsubscribeAll(ctx context.Context, subscriber string) error {
+	// The subscriptions of a subscriber may be spread over several nodes
+	var nodes []*poolNode
+	p.subscriptionsMtx.Lock()
+	for key, node := range p.subscriptions {
+		if key.subscriber != subscriber {
+			continue
+		}
+		delete(p.subscriptions, key)
+		if !slices.Contains(nodes, node) {
+			nodes = append(nodes, node)
+		}
+	}
+	p.subscriptionsMtx.Unlock()
+	if len(nodes) == 0 {
+		return fmt.Errorf("subscriber %s has no subscriptions in the rpc pool", subscriber)
+	}
+
+	var errs []error
+	for _, node := range nodes {
+		if err := node.client.UnsubscribeAll(ctx, subscriber); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", node.addr, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MempoolClient
+
+func (p *RPCPool) UnconfirmedTxs(ctx context.Context, limit *int) (*coretypes.ResultUnconfirmedTxs, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultUnconfirmedTxs, error) {
+		return c.UnconfirmedTxs(ctx, limit)
+	})
+}
+
+func (p *RPCPool) NumUnconfirmedTxs(ctx context.Context) (*coretypes.ResultUnconfirmedTxs, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultUnconfirmedTxs, error) {
+		return c.NumUnconfirmedTxs(ctx)
+	})
+}
+
+func (p *RPCPool) CheckTx(ctx context.Context, tx cmttypes.Tx) (*coretypes.ResultCheckTx, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultCheckTx, error) {
+		return c.CheckTx(ctx, tx)
+	})
+}
+
+// EvidenceClient
+
+func (p *RPCPool) BroadcastEvidence(ctx context.Context, ev cmttypes.Evidence) (*coretypes.ResultBroadcastEvidence, error) {
+	return poolCall(ctx, p, func(c rpcclient.Client) (*coretypes.ResultBroadcastEvidence, error) {
+		return c.BroadcastEvidence(ctx, ev)
+	})
+}
@@ -0,0 +1,286 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/RiemaLabs/probe/logger"
+	cmtjson "github.com/cometbft/cometbft/libs/json"
+	cmtpubsub "github.com/cometbft/cometbft/libs/pubsub"
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	libclient "github.com/cometbft/cometbft/rpc/jsonrpc/client"
+)
+
+const (
+	// DefaultSubscriptionBuffer is the number of events a subscription holds for a slow consumer
+	DefaultSubscriptionBuffer = 100
+
+	// subscriptionReconnectAttempts is the number of redials of the websocket client before the
+	// subscription moves on to the next RPC address
+	subscriptionReconnectAttempts = 5
+
+	subscriptionMinBackoff = time.Second
+	subscriptionMaxBackoff = 30 * time.Second
+)
+
+// Subscription delivers the events of a CometBFT event query, e.g. tm.event='NewBlock'.
+//
+// The subscription owns a websocket connection. It resubscribes after reconnections, and moves to
+// the next configured RPC address when a node stays unreachable. Events are read from the
+// websocket as soon as they arrive, so the node never cancels the subscription because of a slow
+// consumer. When the buffer is full the oldest event is dropped and counted in Dropped.
+type Subscription struct {
+	Query string
+
+	addrs   []string
+	events  chan coretypes.ResultEvent
+	dropped atomic.Uint64
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	errMtx sync.Mutex
+	err    error
+}
+
+// Subscribe subscribes to the given CometBFT event query. The subscription ends when ctx is done
+// or Close is called, and its Events channel is then closed. A bufferSize of 0 uses
+// DefaultSubscriptionBuffer.
+func (cc *ChainClient) Subscribe(ctx context.Context, query string, bufferSize int) (*Subscription, error) {
+	if cc.Config.Transport == TransportREST {
+		return nil, fmt.Errorf("subscriptions are not available on the rest transport: %w", errors.ErrUnsupported)
+	}
+
+	addrs := cc.Config.rpcPoolAddrs()
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no rpc address configured")
+	}
+
+	if bufferSize <= 0 {
+		bufferSize = DefaultSubscriptionBuffer
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	sub := &Subscription{
+		Query:  query,
+		addrs:  addrs,
+		events: make(chan coretypes.ResultEvent, bufferSize),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	// The first subscription is made synchronously so that invalid queries and unreachable
+	// nodes are reported to the caller
+	ws, err := sub.connect(ctx, 0)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go sub.run(ctx, ws)
+
+	return sub, nil
+}
+
+// Events returns the channel the events are delivered on
+func (s *Subscription) Events() <-chan coretypes.ResultEvent {
+	return s.events
+}
+
+// Dropped returns the number of events dropped because the consumer was too slow
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Done is closed when the subscription has ended
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the error that ended the subscription, if any
+func (s *Subscription) Err() error {
+	s.errMtx.Lock()
+	defer s.errMtx.Unlock()
+	return s.err
+}
+
+// Close ends the subscription and waits for its websocket to be closed
+func (s *Subscription) Close() {
+	s.cancel()
+	<-s.done
+}
+
+func (s *Subscription) setErr(err error) {
+	s.errMtx.Lock()
+	s.err = err
+	s.errMtx.Unlock()
+}
+
+// connect opens a websocket to the RPC address at index and subscribes to the query. The
+// subscription is made again every time the websocket client reconnects.
+func (s *Subscription) connect(ctx context.Context, index int) (*libclient.WSClient, error) {
+	addr := s.addrs[index%len(s.addrs)]
+
+	var ws *libclient.WSClient
+	ws, err := libclient.NewWS(addr, "/websocket",
+		libclient.MaxReconnectAttempts(subscriptionReconnectAttempts),
+		libclient.OnReconnect(func() {
+			logger.Info("Websocket reconnected, resubscribing", "addr", addr, "query", s.Query)
+			if err := ws.Subscribe(ctx, s.Query); err != nil {
+				logger.Error("Failed to resubscribe", err, "addr", addr, "query", s.Query)
+			}
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ws.Start(); err != nil {
+		return nil, fmt.Errorf("failed to connect websocket of %s: %w", addr, err)
+	}
+
+	if err := ws.Subscribe(ctx, s.Query); err != nil {
+		_ = ws.Stop()
+		return nil, fmt.Errorf("failed to subscribe to %s on %s: %w", s.Query, addr, err)
+	}
+
+	return ws, nil
+}
+
+// run forwards the events until the context is done, and replaces the websocket client when it
+// gave up reconnecting
+func (s *Subscription) run(ctx context.Context, ws *libclient.WSClient) {
+	defer close(s.done)
+	defer close(s.events)
+
+	index := 0
+	for {
+		s.forward(ctx, ws)
+
+		if ws.IsRunning() {
+			_ = ws.Stop()
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		var err error
+		ws, err = s.reconnect(ctx, &index)
+		if err != nil {
+			s.setErr(err)
+			return
+		}
+	}
+}
+
+// reconnect opens a new websocket, trying the RPC addresses in turn with a capped backoff
+func (s *Subscription) reconnect(ctx context.Context, index *int) (*libclient.WSClient, error) {
+	backoff := subscriptionMinBackoff
+	for {
+		*index++
+		ws, err := s.connect(ctx, *index)
+		if err == nil {
+			return ws, nil
+		}
+
+		logger.Warn("Failed to restore subscription", "query", s.Query, "error", err.Error(), "backoff", backoff.String())
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if backoff > subscriptionMaxBackoff {
+			backoff = subscriptionMaxBackoff
+		}
+	}
+}
+
+// forward reads the websocket responses until the client stops or the context is done
+func (s *Subscription) forward(ctx context.Context, ws *libclient.WSClient) {
+	// A resubscription ends with the websocket client it is made on
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var resubscribing atomic.Bool
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ws.Quit():
+			return
+		case resp, ok := <-ws.ResponsesCh:
+			if !ok {
+				return
+			}
+
+			if resp.Error != nil {
+				if strings.Contains(resp.Error.Error(), cmtpubsub.ErrAlreadySubscribed.Error()) {
+					continue
+				}
+
+				// The node cancels subscriptions when it restarts or when it considers the client
+				// too slow, subscribing again after a second restores the stream. The errors
+				// reported while a resubscription is pending are covered by it.
+				if !resubscribing.CompareAndSwap(false, true) {
+					logger.Debug("Subscription error, resubscription pending", "query", s.Query, "error", resp.Error.Error())
+					continue
+				}
+
+				logger.Warn("Subscription error, resubscribing", "query", s.Query, "error", resp.Error.Error())
+				go func() {
+					defer resubscribing.Store(false)
+					select {
+					case <-ctx.Done():
+						return
+					case <-time.After(subscriptionMinBackoff):
+					}
+					if err := ws.Subscribe(ctx, s.Query); err != nil {
+						logger.Error("Failed to resubscribe", err, "query", s.Query)
+					}
+				}()
+				continue
+			}
+
+			var event coretypes.ResultEvent
+			if err := cmtjson.Unmarshal(resp.Result, &event); err != nil {
+				logger.Warn("Failed to decode event", "query", s.Query, "error", err.Error())
+				continue
+			}
+
+			// The responses to the subscribe calls carry no event
+			if event.Data == nil {
+				continue
+			}
+
+			s.deliver(event)
+		}
+	}
+}
+
+// deliver never blocks, the oldest buffered event makes room for the new one when the buffer is full
+func (s *Subscription) deliver(event coretypes.ResultEvent) {
+	for {
+		select {
+		case s.events <- event:
+			return
+		default:
+		}
+
+		select {
+		case <-s.events:
+			s.dropped.Add(1)
+		default:
+		}
+	}
+}
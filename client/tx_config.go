@@ -0,0 +1,171 @@
+package client
+
+import (
+	"fmt"
+
+	probeCodecTypes "github.com/RiemaLabs/probe/client/codec/types"
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/codec"
+	"github.com/cosmos/cosmos-sdk/codec/types"
+	sdkTypes "github.com/cosmos/cosmos-sdk/types"
+	txTypes "github.com/cosmos/cosmos-sdk/types/tx"
+	gogoproto "github.com/cosmos/gogoproto/proto"
+	protov2 "google.golang.org/protobuf/proto"
+)
+
+// probeTxConfig decodes txs with the SDK decoder, and falls back to a plain protobuf decoding when
+// the registry holds messages loaded from descriptor sets. The unknown field checks of the SDK
+// decoder need compiled descriptors and reject those messages.
+type probeTxConfig struct {
+	client.TxConfig
+
+	cdc      codec.Codec
+	registry *probeCodecTypes.ProbeInterfaceRegistry
+}
+
+func (c probeTxConfig) TxDecoder() sdkTypes.TxDecoder {
+	decode := c.TxConfig.TxDecoder()
+	return func(txBytes []byte) (sdkTypes.Tx, error) {
+		tx, err := decode(txBytes)
+		if err == nil || !c.registry.HasDynamicTypes() {
+			return tx, err
+		}
+
+		protoTx, fallbackErr := decodeProtoTx(c.cdc, txBytes)
+		if fallbackErr != nil {
+			return nil, err
+		}
+
+		return protoTx, nil
+	}
+}
+
+// TxEncoder encodes the txs of decodeProtoTx with their raw body and auth info, the SDK encoder only
+// knows its own tx type
+func (c probeTxConfig) TxEncoder() sdkTypes.TxEncoder {
+	encode := c.TxConfig.TxEncoder()
+	return func(tx sdkTypes.Tx) ([]byte, error) {
+		decoded, ok := tx.(*decodedTx)
+		if !ok {
+			return encode(tx)
+		}
+
+		return c.cdc.Marshal(&txTypes.TxRaw{
+			BodyBytes:     decoded.bodyBytes,
+			AuthInfoBytes: decoded.authInfoBytes,
+			Signatures:    decoded.tx.Signatures,
+		})
+	}
+}
+
+// decodeProtoTx decodes a TxRaw and its body and auth info, unpacking the Any values with the registry
+func decodeProtoTx(cdc codec.Codec, txBytes []byte) (*decodedTx, error) {
+	var raw txTypes.TxRaw
+	if err := cdc.Unmarshal(txBytes, &raw); err != nil {
+		return nil, err
+	}
+
+	var body txTypes.TxBody
+	if err := cdc.Unmarshal(raw.BodyBytes, &body); err != nil {
+		return nil, err
+	}
+
+	var authInfo txTypes.AuthInfo
+	if err := cdc.Unmarshal(raw.AuthInfoBytes, &authInfo); err != nil {
+		return nil, err
+	}
+
+	return &decodedTx{
+		tx: &txTypes.Tx{
+			Body:       &body,
+			AuthInfo:   &authInfo,
+			Signatures: raw.Signatures,
+		},
+		bodyBytes:     raw.BodyBytes,
+		authInfoBytes: raw.AuthInfoBytes,
+	}, nil
+}
+
+// decodedTx is a sdk.Tx decoded by decodeProtoTx. The raw body and auth info are kept, since
+// encoding them again may not give the signed bytes.
+type decodedTx struct {
+	tx *txTypes.Tx
+
+	bodyBytes     []byte
+	authInfoBytes []byte
+}
+
+func (t *decodedTx) GetProtoTx() *txTypes.Tx {
+	return t.tx
+}
+
+func (t *decodedTx) GetMsgs() []sdkTypes.Msg {
+	msgs := make([]sdkTypes.Msg, 0, len(t.tx.Body.Messages))
+	for _, any := range t.tx.Body.Messages {
+		if msg, ok := any.GetCachedValue().(sdkTypes.Msg); ok {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
+
+func (t *decodedTx) GetMsgsV2() ([]protov2.Message, error) {
+	msgs := make([]protov2.Message, 0, len(t.tx.Body.Messages))
+	for _, msg := range t.GetMsgs() {
+		dynamicMsg, ok := msg.(*probeCodecTypes.DynamicMsg)
+		if !ok {
+			return nil, fmt.Errorf("%T has no protobuf v2 form", msg)
+		}
+		msgs = append(msgs, dynamicMsg.Message)
+	}
+	return msgs, nil
+}
+
+// DecodeTxTolerant decodes a tx without failing on the values the registry cannot resolve. The
+// Any values of unknown messages, extension options or public keys are kept with their type URL and
+// raw value, and every failure is returned. The tx is nil when the bytes are not a tx at all.
+func (c Codec) DecodeTxTolerant(txBytes []byte) (*txTypes.Tx, []error) {
+	if sdkTx, err := c.TxConfig.TxDecoder()(txBytes); err == nil {
+		if wrap, ok := sdkTx.(interface{ GetProtoTx() *txTypes.Tx }); ok {
+			return wrap.GetProtoTx(), nil
+		}
+	}
+
+	// The envelope is decoded without unpacking, so that one unknown Any doesn't fail the others
+	var raw txTypes.TxRaw
+	if err := gogoproto.Unmarshal(txBytes, &raw); err != nil {
+		return nil, []error{fmt.Errorf("invalid tx: %w", err)}
+	}
+
+	var errs []error
+	tx := &txTypes.Tx{Body: &txTypes.TxBody{}, AuthInfo: &txTypes.AuthInfo{}, Signatures: raw.Signatures}
+	if err := gogoproto.Unmarshal(raw.BodyBytes, tx.Body); err != nil {
+		errs = append(errs, fmt.Errorf("invalid tx body: %w", err))
+	}
+	if err := gogoproto.Unmarshal(raw.AuthInfoBytes, tx.AuthInfo); err != nil {
+		errs = append(errs, fmt.Errorf("invalid tx auth info: %w", err))
+	}
+
+	for i, any := range tx.Body.Messages {
+		var msg sdkTypes.Msg
+		if err := c.InterfaceRegistry.UnpackAny(any, &msg); err != nil {
+			errs = append(errs, fmt.Errorf("message %d (%s): %w", i, any.TypeUrl, err))
+		}
+	}
+
+	options := append(append([]*types.Any{}, tx.Body.ExtensionOptions...), tx.Body.NonCriticalExtensionOptions...)
+	for _, any := range options {
+		var opt txTypes.TxExtensionOptionI
+		if err := c.InterfaceRegistry.UnpackAny(any, &opt); err != nil {
+			errs = append(errs, fmt.Errorf("extension option %s: %w", any.TypeUrl, err))
+		}
+	}
+
+	for i, signerInfo := range tx.AuthInfo.SignerInfos {
+		if err := signerInfo.UnpackInterfaces(c.InterfaceRegistry); err != nil {
+			errs = append(errs, fmt.Errorf("signer info %d: %w", i, err))
+		}
+	}
+
+	return tx, errs
+}
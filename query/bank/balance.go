@@ -0,0 +1,74 @@
+package bank
+
+import (
+	"context"
+	"fmt"
+
+	"cosmossdk.io/collections"
+	"cosmossdk.io/math"
+	probeQueryTypes "github.com/RiemaLabs/probe/query"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/bech32"
+	bankTypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+)
+
+func BalanceRPC(q *probeQueryTypes.Query, address string, denom string) (*sdk.Coin, error) {
+	return BalanceRPCWithContext(context.Background(), q, address, denom)
+}
+
+// BalanceRPCWithContext is BalanceRPC bounded by ctx. When proofs are verified, the balance is read
+// from the bank store along with its merkle proof instead of the bank query service.
+func BalanceRPCWithContext(ctx context.Context, q *probeQueryTypes.Query, address string, denom string) (*sdk.Coin, error) {
+	if q.Client.Config.VerifyProofs {
+		return verifiedBalance(ctx, q, address, denom)
+	}
+
+	queryClient := bankTypes.NewQueryClient(q.Client)
+	ctx = q.HeightContext(ctx)
+	res, err := queryClient.Balance(ctx, &bankTypes.QueryBalanceRequest{Address: address, Denom: denom})
+	if err != nil {
+		return nil, err
+	}
+	return res.Balance, nil
+}
+
+func verifiedBalance(ctx context.Context, q *probeQueryTypes.Query, address string, denom string) (*sdk.Coin, error) {
+	if err := sdk.ValidateDenom(denom); err != nil {
+		return nil, err
+	}
+
+	_, addr, err := bech32.DecodeAndConvert(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %s: %w", address, err)
+	}
+
+	key, err := collections.EncodeKeyWithPrefix(
+		bankTypes.BalancesPrefix,
+		collections.PairKeyCodec(sdk.AccAddressKey, collections.StringKey),
+		collections.Join(sdk.AccAddress(addr), denom),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var height int64
+	if q.Options != nil {
+		height = q.Options.Height
+	}
+
+	value, err := q.Client.QueryStore(ctx, bankTypes.StoreKey, key, height)
+	if err != nil {
+		return nil, err
+	}
+
+	// A missing balance is a proven zero balance
+	coin := sdk.Coin{Denom: denom, Amount: math.ZeroInt()}
+	if value != nil {
+		coin.Amount, err = bankTypes.BalanceValueCodec.Decode(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode balance: %w", err)
+		}
+	}
+
+	return &coin, nil
+}
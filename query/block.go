@@ -3,16 +3,30 @@
 package query
 
 import (
+	"context"
+	"fmt"
+
+	"github.com/RiemaLabs/probe/client"
+	"github.com/RiemaLabs/probe/utils"
 	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+	txTypes "github.com/cosmos/cosmos-sdk/types/tx"
 )
 
 func BlockResultsRPC(q *Query) (*coretypes.ResultBlockResults, error) {
+	return BlockResultsRPCWithContext(context.Background(), q)
+}
+
+// BlockResultsRPCWithContext is BlockResultsRPC bounded by ctx, every attempt is also bounded by the configured timeout
+func BlockResultsRPCWithContext(ctx context.Context, q *Query) (*coretypes.ResultBlockResults, error) {
+	if err := q.Client.RequireRPC("block results"); err != nil {
+		return nil, err
+	}
+
 	var height int64
-	ctx, cancel := q.GetQueryContext()
-	defer cancel()
 	// If height is not specified, default value is 0, query the latest available block then
 	if q.Options.Height == 0 {
-		resStatus, err := q.Client.RPCClient.Status(ctx)
+		resStatus, err := StatusRPCWithContext(ctx, q)
 		if err != nil {
 			return nil, err
 		}
@@ -20,21 +34,33 @@ func BlockResultsRPC(q *Query) (*coretypes.ResultBlockResults, error) {
 	} else {
 		height = q.Options.Height
 	}
-	res, err := q.Client.RPCClient.BlockResults(ctx, &height)
+
+	var res *coretypes.ResultBlockResults
+	err := q.Client.Retry(ctx, func(ctx context.Context) (err error) {
+		res, err = q.Client.RPCClient.BlockResults(ctx, &height)
+		return err
+	})
 	if err != nil {
-		return nil, err
+		return nil, client.HeightError(err, height)
 	}
 
 	return res, nil
 }
 
 func BlockRPC(q *Query) (*coretypes.ResultBlock, error) {
+	return BlockRPCWithContext(context.Background(), q)
+}
+
+// BlockRPCWithContext is BlockRPC bounded by ctx, every attempt is also bounded by the configured timeout
+func BlockRPCWithContext(ctx context.Context, q *Query) (*coretypes.ResultBlock, error) {
+	if err := q.Client.RequireRPC("block"); err != nil {
+		return nil, err
+	}
+
 	var height int64
-	ctx, cancel := q.GetQueryContext()
-	defer cancel()
 	// If height is not specified, default value is 0, query the latest available block then
 	if q.Options.Height == 0 {
-		resStatus, err := q.Client.RPCClient.Status(ctx)
+		resStatus, err := StatusRPCWithContext(ctx, q)
 		if err != nil {
 			return nil, err
 		}
@@ -42,10 +68,36 @@ func BlockRPC(q *Query) (*coretypes.ResultBlock, error) {
 	} else {
 		height = q.Options.Height
 	}
-	res, err := q.Client.RPCClient.Block(ctx, &height)
+
+	var res *coretypes.ResultBlock
+	err := q.Client.Retry(ctx, func(ctx context.Context) (err error) {
+		res, err = q.Client.RPCClient.Block(ctx, &height)
+		return err
+	})
 	if err != nil {
+		return nil, client.HeightError(err, height)
+	}
+
+	if err := q.Client.VerifyBlock(ctx, res.Block); err != nil {
 		return nil, err
 	}
 
 	return res, nil
 }
+
+// BlockTxs decodes the txs of a block, with the codec of the client schedule matching the block
+func BlockTxs(q *Query, block *cmttypes.Block) ([]*txTypes.Tx, error) {
+	codec := q.Client.CodecForHeader(&block.Header, q.Client.Codec)
+	decoder := codec.TxConfig.TxDecoder()
+
+	txs := make([]*txTypes.Tx, 0, len(block.Txs))
+	for i, rawTx := range block.Txs {
+		tx, err := utils.TxBytesToProto(rawTx, decoder)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode tx %d of block %d: %w", i, block.Height, err)
+		}
+		txs = append(txs, tx)
+	}
+
+	return txs, nil
+}
@@ -3,14 +3,27 @@
 package query
 
 import (
+	"context"
+
 	coretypes "github.com/cometbft/cometbft/rpc/core/types"
 )
 
 // StatusRPC returns information about a node status
 func StatusRPC(q *Query) (*coretypes.ResultStatus, error) {
-	ctx, cancel := q.GetQueryContext()
-	defer cancel()
-	res, err := q.Client.RPCClient.Status(ctx)
+	return StatusRPCWithContext(context.Background(), q)
+}
+
+// StatusRPCWithContext is StatusRPC bounded by ctx, every attempt is also bounded by the configured timeout
+func StatusRPCWithContext(ctx context.Context, q *Query) (*coretypes.ResultStatus, error) {
+	if err := q.Client.RequireRPC("node status"); err != nil {
+		return nil, err
+	}
+
+	var res *coretypes.ResultStatus
+	err := q.Client.Retry(ctx, func(ctx context.Context) (err error) {
+		res, err = q.Client.RPCClient.Status(ctx)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
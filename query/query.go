@@ -8,6 +8,7 @@ import (
 
 	"github.com/RiemaLabs/probe/client"
 	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
 	txTypes "github.com/cosmos/cosmos-sdk/types/tx"
 )
 
@@ -17,26 +18,109 @@ type Query struct {
 }
 
 // GetQueryContext returns a context that includes the height and uses the timeout from the config
+//
+// Deprecated: the query functions take a context, use HeightContext to pin it to the height
 func (q *Query) GetQueryContext() (context.Context, context.CancelFunc) {
-	timeout, _ := time.ParseDuration(q.Client.Config.Timeout) // Timeout is validated in the config so no error check
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	return ctx, cancel
+	ctx := q.HeightContext(context.Background())
+
+	// NewChainClient validates the timeout, a config changed afterwards gets a context without deadline
+	// rather than one that is already expired
+	timeout, err := time.ParseDuration(q.Client.Config.Timeout)
+	if err != nil || timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// HeightContext pins the gRPC module queries made with the returned context to Options.Height
+func (q *Query) HeightContext(ctx context.Context) context.Context {
+	if q.Options == nil {
+		return ctx
+	}
+	return client.SetHeightOnContext(ctx, q.Options.Height)
+}
+
+// Snapshot resolves the latest height once and returns a copy of q pinned to it, so that a batch
+// of queries reads a consistent state. A query that already has a height is pinned to that height.
+func (q *Query) Snapshot(ctx context.Context) (*Query, error) {
+	options := QueryOptions{}
+	if q.Options != nil {
+		options = *q.Options
+	}
+
+	if options.Height == 0 {
+		status, err := StatusRPCWithContext(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		options.Height = status.SyncInfo.LatestBlockHeight
+	}
+
+	return &Query{Client: q.Client, Options: &options}, nil
+}
+
+// WasmQuery returns a contract query that shares the client and the height of q
+func (q *Query) WasmQuery(contractAddress string) *WasmQuery {
+	options := &WasmQueryOptions{ContractAddress: contractAddress}
+	if q.Options != nil {
+		options.Height = q.Options.Height
+	}
+	return &WasmQuery{Client: q.Client, Options: options}
 }
 
 func (q *Query) BlockResults() (*coretypes.ResultBlockResults, error) {
 	return BlockResultsRPC(q)
 }
 
+func (q *Query) BlockResultsWithContext(ctx context.Context) (*coretypes.ResultBlockResults, error) {
+	return BlockResultsRPCWithContext(ctx, q)
+}
+
 func (q *Query) Block() (*coretypes.ResultBlock, error) {
 	return BlockRPC(q)
 }
 
+func (q *Query) BlockWithContext(ctx context.Context) (*coretypes.ResultBlock, error) {
+	return BlockRPCWithContext(ctx, q)
+}
+
 // Tx returns the Tx and all contained messages/TxResponse.
 func (q *Query) TxByHeight() (*txTypes.GetTxsEventResponse, error) {
 	return TxsAtHeightRPC(q, q.Options.Height, q.Client.Codec)
 }
 
+func (q *Query) TxByHeightWithContext(ctx context.Context) (*txTypes.GetTxsEventResponse, error) {
+	return TxsAtHeightRPCWithContext(ctx, q, q.Options.Height, q.Client.Codec)
+}
+
+// TxByHash returns the tx with the given hex encoded hash and its response, with prove the inclusion proof is verified
+func (q *Query) TxByHash(hash string, prove bool) (*txTypes.GetTxResponse, error) {
+	return TxByHashRPC(q, hash, prove)
+}
+
+func (q *Query) TxByHashWithContext(ctx context.Context, hash string, prove bool) (*txTypes.GetTxResponse, error) {
+	return TxByHashRPCWithContext(ctx, q, hash, prove)
+}
+
+// TxsByHash returns the txs with the given hex encoded hashes, looked up concurrently, in the order of the hashes
+func (q *Query) TxsByHash(hashes []string, prove bool) ([]*txTypes.GetTxResponse, error) {
+	return TxsByHashRPC(q, hashes, prove)
+}
+
+func (q *Query) TxsByHashWithContext(ctx context.Context, hashes []string, prove bool) ([]*txTypes.GetTxResponse, error) {
+	return TxsByHashRPCWithContext(ctx, q, hashes, prove)
+}
+
+// WaitForTx waits until the tx with the given hex encoded hash is included and returns its response, see WaitForTxRPC
+func (q *Query) WaitForTx(ctx context.Context, hash string) (*sdk.TxResponse, error) {
+	return WaitForTxRPC(ctx, q, hash)
+}
+
 // Status returns information about a node status
 func (q *Query) Status() (*coretypes.ResultStatus, error) {
 	return StatusRPC(q)
 }
+
+func (q *Query) StatusWithContext(ctx context.Context) (*coretypes.ResultStatus, error) {
+	return StatusRPCWithContext(ctx, q)
+}
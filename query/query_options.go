@@ -8,5 +8,9 @@ import (
 
 type QueryOptions struct {
 	Pagination *query.PageRequest
-	Height     int64
+	// Height pins block, tx and module state queries to a block height, 0 queries the latest state
+	Height int64
+	// RequireVerified rejects the queries whose results cannot be proven, like the paginated module
+	// queries, when Config.VerifyProofs is set, instead of returning their results unverified
+	RequireVerified bool
 }
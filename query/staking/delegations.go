@@ -1,12 +1,27 @@
 package staking
 
 import (
+	"context"
+
 	probeQueryTypes "github.com/RiemaLabs/probe/query"
 	queryTypes "github.com/cosmos/cosmos-sdk/types/query"
 	stakingTypes "github.com/cosmos/cosmos-sdk/x/staking/types"
 )
 
 func DelegatorDelegationsRPC(q *probeQueryTypes.Query, delegatorAddress string, paginationKey []byte) (*stakingTypes.QueryDelegatorDelegationsResponse, error) {
+	return DelegatorDelegationsRPCWithContext(context.Background(), q, delegatorAddress, paginationKey)
+}
+
+// DelegatorDelegationsRPCWithContext is DelegatorDelegationsRPC bounded by ctx, every attempt is also bounded by the configured timeout.
+//
+// The delegations are read from the staking query service and have no merkle proof. When
+// Config.VerifyProofs is set, the result is returned unverified and a warning is logged, unless
+// Options.RequireVerified rejects the query.
+func DelegatorDelegationsRPCWithContext(ctx context.Context, q *probeQueryTypes.Query, delegatorAddress string, paginationKey []byte) (*stakingTypes.QueryDelegatorDelegationsResponse, error) {
+	if err := unverified(q, "delegator delegations"); err != nil {
+		return nil, err
+	}
+
 	req := stakingTypes.QueryDelegatorDelegationsRequest{
 		DelegatorAddr: delegatorAddress,
 	}
@@ -18,8 +33,7 @@ func DelegatorDelegationsRPC(q *probeQueryTypes.Query, delegatorAddress string,
 	}
 
 	queryClient := stakingTypes.NewQueryClient(q.Client)
-	ctx, cancel := q.GetQueryContext()
-	defer cancel()
+	ctx = q.HeightContext(ctx)
 	res, err := queryClient.DelegatorDelegations(ctx, &req)
 	if err != nil {
 		return nil, err
@@ -28,11 +42,16 @@ func DelegatorDelegationsRPC(q *probeQueryTypes.Query, delegatorAddress string,
 }
 
 func DelegatorDelegations(q *probeQueryTypes.Query, address string) (*stakingTypes.QueryDelegatorDelegationsResponse, error) {
+	return DelegatorDelegationsWithContext(context.Background(), q, address)
+}
+
+// DelegatorDelegationsWithContext is DelegatorDelegations bounded by ctx
+func DelegatorDelegationsWithContext(ctx context.Context, q *probeQueryTypes.Query, address string) (*stakingTypes.QueryDelegatorDelegationsResponse, error) {
 	var paginationKey []byte
 
 	if q.Options.Pagination != nil && q.Options.Pagination.Key != nil {
 		paginationKey = q.Options.Pagination.Key
 	}
 
-	return DelegatorDelegationsRPC(q, address, paginationKey)
+	return DelegatorDelegationsRPCWithContext(ctx, q, address, paginationKey)
 }
@@ -0,0 +1,64 @@
+package staking
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/RiemaLabs/probe/client"
+	probeQueryTypes "github.com/RiemaLabs/probe/query"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/bech32"
+	stakingTypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+)
+
+func ValidatorRPC(q *probeQueryTypes.Query, validatorAddress string) (*stakingTypes.Validator, error) {
+	return ValidatorRPCWithContext(context.Background(), q, validatorAddress)
+}
+
+// ValidatorRPCWithContext is ValidatorRPC bounded by ctx. When proofs are verified, the validator is
+// read from the staking store along with its merkle proof instead of the staking query service.
+func ValidatorRPCWithContext(ctx context.Context, q *probeQueryTypes.Query, validatorAddress string) (*stakingTypes.Validator, error) {
+	if q.Client.Config.VerifyProofs {
+		return verifiedValidator(ctx, q, validatorAddress)
+	}
+
+	queryClient := stakingTypes.NewQueryClient(q.Client)
+	ctx = q.HeightContext(ctx)
+	res, err := queryClient.Validator(ctx, &stakingTypes.QueryValidatorRequest{ValidatorAddr: validatorAddress})
+	if err != nil {
+		return nil, err
+	}
+	return &res.Validator, nil
+}
+
+func verifiedValidator(ctx context.Context, q *probeQueryTypes.Query, validatorAddress string) (*stakingTypes.Validator, error) {
+	_, addr, err := bech32.DecodeAndConvert(validatorAddress)
+	if err != nil {
+		return nil, fmt.Errorf("invalid validator address %s: %w", validatorAddress, err)
+	}
+
+	var height int64
+	if q.Options != nil {
+		height = q.Options.Height
+	}
+
+	value, err := q.Client.QueryStore(ctx, stakingTypes.StoreKey, stakingTypes.GetValidatorKey(sdk.ValAddress(addr)), height)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, fmt.Errorf("validator %s: %w", validatorAddress, client.ErrNotFound)
+	}
+
+	var validator stakingTypes.Validator
+	if err := q.Client.Codec.Marshaler.Unmarshal(value, &validator); err != nil {
+		return nil, fmt.Errorf("failed to decode validator: %w", err)
+	}
+
+	if err := codectypes.UnpackInterfaces(&validator, q.Client.Codec.InterfaceRegistry); err != nil {
+		return nil, err
+	}
+
+	return &validator, nil
+}
@@ -1,12 +1,30 @@
 package staking
 
 import (
+	"context"
+	"fmt"
+
+	"github.com/RiemaLabs/probe/client"
+	"github.com/RiemaLabs/probe/logger"
 	probeQueryTypes "github.com/RiemaLabs/probe/query"
 	queryTypes "github.com/cosmos/cosmos-sdk/types/query"
 	stakingTypes "github.com/cosmos/cosmos-sdk/x/staking/types"
 )
 
 func ValidatorsRPC(q *probeQueryTypes.Query, status *stakingTypes.BondStatus, paginationKey []byte) (*stakingTypes.QueryValidatorsResponse, error) {
+	return ValidatorsRPCWithContext(context.Background(), q, status, paginationKey)
+}
+
+// ValidatorsRPCWithContext is ValidatorsRPC bounded by ctx, every attempt is also bounded by the configured timeout.
+//
+// A page of validators is read from the staking query service and has no merkle proof. When
+// Config.VerifyProofs is set, the result is returned unverified and a warning is logged, unless
+// Options.RequireVerified rejects the query. ValidatorRPC reads a verified validator.
+func ValidatorsRPCWithContext(ctx context.Context, q *probeQueryTypes.Query, status *stakingTypes.BondStatus, paginationKey []byte) (*stakingTypes.QueryValidatorsResponse, error) {
+	if err := unverified(q, "validators"); err != nil {
+		return nil, err
+	}
+
 	req := stakingTypes.QueryValidatorsRequest{}
 
 	// Set the status if it is not nil
@@ -22,8 +40,7 @@ func ValidatorsRPC(q *probeQueryTypes.Query, status *stakingTypes.BondStatus, pa
 	}
 
 	queryClient := stakingTypes.NewQueryClient(q.Client)
-	ctx, cancel := q.GetQueryContext()
-	defer cancel()
+	ctx = q.HeightContext(ctx)
 	res, err := queryClient.Validators(ctx, &req)
 	if err != nil {
 		return nil, err
@@ -32,11 +49,29 @@ func ValidatorsRPC(q *probeQueryTypes.Query, status *stakingTypes.BondStatus, pa
 }
 
 func Validators(q *probeQueryTypes.Query, status *stakingTypes.BondStatus) (*stakingTypes.QueryValidatorsResponse, error) {
+	return ValidatorsWithContext(context.Background(), q, status)
+}
+
+// ValidatorsWithContext is Validators bounded by ctx
+func ValidatorsWithContext(ctx context.Context, q *probeQueryTypes.Query, status *stakingTypes.BondStatus) (*stakingTypes.QueryValidatorsResponse, error) {
 	var paginationKey []byte
 
 	if q.Options.Pagination != nil && q.Options.Pagination.Key != nil {
 		paginationKey = q.Options.Pagination.Key
 	}
 
-	return ValidatorsRPC(q, status, paginationKey)
+	return ValidatorsRPCWithContext(ctx, q, status, paginationKey)
+}
+
+// unverified rejects a query whose result cannot be proven when Config.VerifyProofs and
+// Options.RequireVerified are set, and otherwise warns that the result is unverified
+func unverified(q *probeQueryTypes.Query, query string) error {
+	if !q.Client.Config.VerifyProofs {
+		return nil
+	}
+	if q.Options != nil && q.Options.RequireVerified {
+		return fmt.Errorf("%w: %s query results cannot be proven", client.ErrProofVerification, query)
+	}
+	logger.Warn("Query results cannot be proven, returning an unverified result", "query", query)
+	return nil
 }
@@ -0,0 +1,147 @@
+package query
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/RiemaLabs/probe/client"
+	"github.com/RiemaLabs/probe/logger"
+	abci "github.com/cometbft/cometbft/abci/types"
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	txTypes "github.com/cosmos/cosmos-sdk/types/tx"
+)
+
+// TxEvent is a tx delivered by SubscribeTxs
+type TxEvent struct {
+	Tx         *txTypes.Tx
+	TxResponse *sdk.TxResponse
+	// Err is set instead of Tx and TxResponse when the header of the block including the tx
+	// could not be fetched, the tx cannot be decoded and timestamped without it, or when the tx
+	// could not be decoded
+	Err error
+}
+
+// SubscribeBlocks delivers every new block. The channel is closed when the subscription ends, see
+// client.Subscription for reconnection and backpressure.
+func SubscribeBlocks(ctx context.Context, q *Query) (<-chan *coretypes.ResultBlock, *client.Subscription, error) {
+	sub, err := q.Client.Subscribe(ctx, cmttypes.EventQueryNewBlock.String(), 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	blocks := make(chan *coretypes.ResultBlock)
+	go func() {
+		defer close(blocks)
+		for event := range sub.Events() {
+			data, ok := event.Data.(cmttypes.EventDataNewBlock)
+			if !ok {
+				logger.Warn("Unexpected block event", "query", event.Query)
+				continue
+			}
+
+			if err := q.Client.VerifyBlock(ctx, data.Block); err != nil {
+				logger.Error("Dropping unverified block", err, "height", data.Block.Height)
+				continue
+			}
+
+			select {
+			case blocks <- &coretypes.ResultBlock{BlockID: data.BlockID, Block: data.Block}:
+			case <-sub.Done():
+				return
+			}
+		}
+	}()
+
+	return blocks, sub, nil
+}
+
+// SubscribeTxs delivers every tx matching the filter, e.g. "message.sender='...'". An empty filter
+// delivers all txs. The txs are decoded with BuildGetTxsEventResponse like TxsRPC results, with
+// the codec matching the block, and the timestamp is the time of the block including the tx.
+// A tx whose block header cannot be fetched, or which cannot be decoded, is delivered with
+// TxEvent.Err set.
+func SubscribeTxs(ctx context.Context, q *Query, filter string) (<-chan TxEvent, *client.Subscription, error) {
+	query := cmttypes.EventQueryTx.String()
+	if filter != "" {
+		query += " AND " + filter
+	}
+
+	sub, err := q.Client.Subscribe(ctx, query, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	txs := make(chan TxEvent)
+	go func() {
+		defer close(txs)
+
+		var (
+			headerHeight int64
+			header       *cmttypes.Header
+		)
+		for event := range sub.Events() {
+			data, ok := event.Data.(cmttypes.EventDataTx)
+			if !ok {
+				logger.Warn("Unexpected tx event", "query", event.Query)
+				continue
+			}
+
+			// Txs of a block arrive together, so the header is only looked up once per height. A
+			// failed lookup is retried with the next tx of the block.
+			var txEvent TxEvent
+			if data.Height != headerHeight {
+				verified, err := blockHeader(ctx, q, data.Height)
+				if err != nil {
+					logger.Error("Failed to get block header of tx", err, "height", data.Height)
+					txEvent.Err = fmt.Errorf("failed to get block header of tx %X at height %d: %w", cmttypes.Tx(data.Tx).Hash(), data.Height, err)
+				} else {
+					headerHeight, header = data.Height, verified
+				}
+			}
+
+			if txEvent.Err == nil {
+				decoded, err := decodeTxEvent(q, header, data.TxResult)
+				if err != nil {
+					logger.Error("Failed to decode tx event", err, "height", data.Height)
+					txEvent.Err = fmt.Errorf("failed to decode tx %X at height %d: %w", cmttypes.Tx(data.Tx).Hash(), data.Height, err)
+				} else {
+					txEvent = decoded
+				}
+			}
+
+			select {
+			case txs <- txEvent:
+			case <-sub.Done():
+				return
+			}
+		}
+	}()
+
+	return txs, sub, nil
+}
+
+// decodeTxEvent decodes the tx of an event with the codec matching the block of the header
+func decodeTxEvent(q *Query, header *cmttypes.Header, txResult abci.TxResult) (TxEvent, error) {
+	codec := q.Client.CodecForHeader(header, q.Client.Codec)
+	res, err := BuildGetTxsEventResponse(header.Time, &coretypes.ResultTxSearch{
+		Txs:        []*coretypes.ResultTx{resultTx(txResult)},
+		TotalCount: 1,
+	}, codec.TxConfig.TxDecoder(), nil)
+	if err != nil {
+		return TxEvent{}, err
+	}
+
+	return TxEvent{Tx: res.Txs[0], TxResponse: res.TxResponses[0]}, nil
+}
+
+func resultTx(txResult abci.TxResult) *coretypes.ResultTx {
+	return &coretypes.ResultTx{
+		Hash:     cmttypes.Tx(txResult.Tx).Hash(),
+		Height:   txResult.Height,
+		Index:    txResult.Index,
+		TxResult: txResult.Result,
+		Tx:       txResult.Tx,
+	}
+}
@@ -1,72 +1,283 @@
 package query
 
 import (
+	"context"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/RiemaLabs/probe/client"
+	"github.com/RiemaLabs/probe/logger"
 	"github.com/RiemaLabs/probe/utils"
 	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	cmttypes "github.com/cometbft/cometbft/types"
 	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/types/query"
 	txTypes "github.com/cosmos/cosmos-sdk/types/tx"
+	"github.com/cosmos/gogoproto/proto"
 )
 
 // TxsAtHeightRPC Get All Transactions for the given block height regardless of pagination.
 // Other query options can be specified with the GetTxsEventRequest.
 //
-// This version only uses the 26657 RPC endpoint (CometBFT).
+// This version uses the 26657 RPC endpoint (CometBFT), or the app gRPC server
+// when the gRPC transport is configured. On nodes without tx indexing, the txs are built from the
+// block and its results with TxsFromBlockRPC.
 func TxsAtHeightRPC(q *Query, height int64, codec client.Codec) (*txTypes.GetTxsEventResponse, error) {
-	orderBy := txTypes.OrderBy_ORDER_BY_ASC
-	req := &txTypes.GetTxsEventRequest{OrderBy: orderBy, Page: 1, Limit: 100, Query: "tx.height=" + fmt.Sprintf("%d", height)}
-	res, err := TxsRPC(q, height, req, codec)
+	return TxsAtHeightRPCWithContext(context.Background(), q, height, codec)
+}
+
+// TxsAtHeightRPCWithContext is TxsAtHeightRPC bounded by ctx, every attempt is also bounded by the configured timeout
+func TxsAtHeightRPCWithContext(ctx context.Context, q *Query, height int64, codec client.Codec) (*txTypes.GetTxsEventResponse, error) {
+	// The tx service of the app gRPC server returns decoded txs with their timestamp
+	if q.Client.UsesGRPC() {
+		res, _, err := txsAtHeight(ctx, func(ctx context.Context, req *txTypes.GetTxsEventRequest) (*txTypes.GetTxsEventResponse, []TxDiagnostic, error) {
+			res, err := txTypes.NewServiceClient(q.Client).GetTxsEvent(ctx, req)
+			return res, nil, err
+		}, height)
+		if err != nil && isTxIndexingDisabled(q, err) {
+			return TxsFromBlockRPCWithContext(ctx, q, height, codec)
+		}
+		return res, err
+	}
+
+	header, err := blockHeader(ctx, q, height)
 	if err != nil {
 		return nil, err
 	}
 
-	if res.Total > 100 {
-		totalPages := res.Total / 100
-		txs := res.Txs
-		txResponses := res.TxResponses
-		for i := uint64(2); i <= totalPages; i++ {
-			req := &txTypes.GetTxsEventRequest{OrderBy: orderBy, Page: i, Limit: 100, Query: "tx.height=" + fmt.Sprintf("%d", height)}
-			res, err := TxsRPC(q, height, req, codec)
-			if err != nil {
-				return nil, err
+	codec = q.Client.CodecForHeader(header, codec)
+	res, _, err := txsAtHeight(ctx, func(ctx context.Context, req *txTypes.GetTxsEventRequest) (*txTypes.GetTxsEventResponse, []TxDiagnostic, error) {
+		txs, nextKey, err := searchTxPage(ctx, q, req)
+		if err != nil {
+			return nil, nil, err
+		}
+		res, err := BuildGetTxsEventResponse(header.Time, txs, codec.TxConfig.TxDecoder(), nextKey)
+		return res, nil, err
+	}, height)
+	if err != nil && isTxIndexingDisabled(q, err) {
+		return TxsFromBlockRPCWithContext(ctx, q, height, codec)
+	}
+	return res, err
+}
+
+// TxsAtHeightRPCTolerant is TxsAtHeightRPC with the tolerant decoding of TxsRPCTolerant
+func TxsAtHeightRPCTolerant(q *Query, height int64, codec client.Codec) (*txTypes.GetTxsEventResponse, []TxDiagnostic, error) {
+	return TxsAtHeightRPCTolerantWithContext(context.Background(), q, height, codec)
+}
+
+// TxsAtHeightRPCTolerantWithContext is TxsAtHeightRPCTolerant bounded by ctx
+func TxsAtHeightRPCTolerantWithContext(ctx context.Context, q *Query, height int64, codec client.Codec) (*txTypes.GetTxsEventResponse, []TxDiagnostic, error) {
+	if err := q.Client.RequireRPC("tolerant tx decoding"); err != nil {
+		return nil, nil, err
+	}
+
+	header, err := blockHeader(ctx, q, height)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	codec = q.Client.CodecForHeader(header, codec)
+	res, diagnostics, err := txsAtHeight(ctx, func(ctx context.Context, req *txTypes.GetTxsEventRequest) (*txTypes.GetTxsEventResponse, []TxDiagnostic, error) {
+		txs, nextKey, err := searchTxPage(ctx, q, req)
+		if err != nil {
+			return nil, nil, err
+		}
+		res, diagnostics := BuildTolerantTxsEventResponse(header.Time, txs, codec, nextKey)
+		return res, diagnostics, nil
+	}, height)
+	if err != nil && isTxIndexingDisabled(q, err) {
+		return TxsFromBlockRPCTolerantWithContext(ctx, q, height, codec)
+	}
+	return res, diagnostics, err
+}
+
+const txsPerPage = 100
+
+// MaxConcurrentTxPages bounds the pages fetched at once by TxsAtHeightRPC
+var MaxConcurrentTxPages = 4
+
+// txsAtHeight fetches every page of the txs of a block. The first page gives the total, the
+// remaining pages are then fetched concurrently and the txs are returned in block order.
+func txsAtHeight(
+	ctx context.Context,
+	fetch func(context.Context, *txTypes.GetTxsEventRequest) (*txTypes.GetTxsEventResponse, []TxDiagnostic, error),
+	height int64,
+) (*txTypes.GetTxsEventResponse, []TxDiagnostic, error) {
+	pageRequest := func(page uint64) *txTypes.GetTxsEventRequest {
+		return &txTypes.GetTxsEventRequest{
+			OrderBy: txTypes.OrderBy_ORDER_BY_ASC,
+			Page:    page,
+			Limit:   txsPerPage,
+			Query:   fmt.Sprintf("tx.height=%d", height),
+		}
+	}
+
+	first, diagnostics, err := fetch(ctx, pageRequest(1))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	totalPages := (first.Total + txsPerPage - 1) / txsPerPage
+	if totalPages <= 1 {
+		return first, diagnostics, nil
+	}
+
+	pages := make([]*txTypes.GetTxsEventResponse, totalPages)
+	pageDiagnostics := make([][]TxDiagnostic, totalPages)
+	pages[0], pageDiagnostics[0] = first, diagnostics
+
+	err = runConcurrently(ctx, int(totalPages)-1, MaxConcurrentTxPages, func(ctx context.Context, i int) error {
+		page := uint64(i) + 2
+		res, diagnostics, err := fetch(ctx, pageRequest(page))
+		if err != nil {
+			return fmt.Errorf("page %d of the txs at height %d: %w", page, height, err)
+		}
+		pages[page-1], pageDiagnostics[page-1] = res, diagnostics
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	txs := make([]*txTypes.Tx, 0, first.Total)
+	txResponses := make([]*sdk.TxResponse, 0, first.Total)
+	diagnostics = nil
+	for i, page := range pages {
+		txs = append(txs, page.Txs...)
+		txResponses = append(txResponses, page.TxResponses...)
+		diagnostics = append(diagnostics, pageDiagnostics[i]...)
+	}
+
+	return &txTypes.GetTxsEventResponse{
+		Txs:         txs,
+		TxResponses: txResponses,
+		Pagination: &query.PageResponse{
+			NextKey: nil,
+			Total:   first.Total,
+		},
+		Total: first.Total,
+	}, diagnostics, nil
+}
+
+// runConcurrently calls fn for every index below n, with at most limit calls at once. The first
+// failure cancels the calls that are still running and is returned.
+func runConcurrently(ctx context.Context, n, limit int, fn func(ctx context.Context, i int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, n)
+	sem := make(chan struct{}, max(limit, 1))
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+
+			if err := fn(ctx, i); err != nil {
+				errs[i] = err
+				cancel()
 			}
-			txs = append(txs, res.Txs...)
-			txResponses = append(txResponses, res.TxResponses...)
+		}(i)
+	}
+	wg.Wait()
+
+	// The cancellations caused by the first failure are not reported
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return err
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
 		}
-		return &txTypes.GetTxsEventResponse{
-			Txs:         txs,
-			TxResponses: txResponses,
-			Pagination: &query.PageResponse{
-				NextKey: nil,
-				Total:   res.Total,
-			},
-			Total: res.Total,
-		}, nil
 	}
-	return res, nil
+
+	return nil
 }
 
 // TxRPC Get Transactions for the given block height.
 // Other query options can be specified with the GetTxsEventRequest.
 //
-// This version only uses the 26657 RPC endpoint (CometBFT).
+// This version uses the 26657 RPC endpoint (CometBFT), or the app gRPC server
+// when the gRPC transport is configured. Over the RPC, the txs are decoded with the codec of the
+// client schedule matching the block, codec is used for the heights it doesn't cover.
 func TxsRPC(q *Query, height int64, req *txTypes.GetTxsEventRequest, codec client.Codec) (*txTypes.GetTxsEventResponse, error) {
-	ctx, cancel := q.GetQueryContext()
-	defer cancel()
+	return TxsRPCWithContext(context.Background(), q, height, req, codec)
+}
+
+// TxsRPCWithContext is TxsRPC bounded by ctx, every attempt is also bounded by the configured timeout
+func TxsRPCWithContext(ctx context.Context, q *Query, height int64, req *txTypes.GetTxsEventRequest, codec client.Codec) (*txTypes.GetTxsEventResponse, error) {
+	// The tx service of the app gRPC server returns decoded txs with their timestamp
+	if q.Client.UsesGRPC() {
+		return txTypes.NewServiceClient(q.Client).GetTxsEvent(ctx, req)
+	}
 
-	header, err := q.Client.RPCClient.Header(ctx, &height)
+	header, txs, nextKey, err := searchTxs(ctx, q, height, req)
 	if err != nil {
 		return nil, err
 	}
 
-	timestamp := header.Header.Time
+	codec = q.Client.CodecForHeader(header, codec)
+	return BuildGetTxsEventResponse(header.Time, txs, codec.TxConfig.TxDecoder(), nextKey)
+}
+
+// TxsRPCTolerant is TxsRPC with a tolerant decoding: a tx that cannot be decoded doesn't fail the
+// query. Its TxResponse is still returned with the raw tx bytes, the Any values that cannot be
+// resolved are kept with their type URL and raw value, and the failures are reported in the
+// diagnostics, one entry per tx.
+//
+// The txs are always fetched from the CometBFT RPC, as the app gRPC server fails on such txs.
+func TxsRPCTolerant(q *Query, height int64, req *txTypes.GetTxsEventRequest, codec client.Codec) (*txTypes.GetTxsEventResponse, []TxDiagnostic, error) {
+	return TxsRPCTolerantWithContext(context.Background(), q, height, req, codec)
+}
+
+// TxsRPCTolerantWithContext is TxsRPCTolerant bounded by ctx
+func TxsRPCTolerantWithContext(ctx context.Context, q *Query, height int64, req *txTypes.GetTxsEventRequest, codec client.Codec) (*txTypes.GetTxsEventResponse, []TxDiagnostic, error) {
+	if err := q.Client.RequireRPC("tolerant tx decoding"); err != nil {
+		return nil, nil, err
+	}
+
+	header, txs, nextKey, err := searchTxs(ctx, q, height, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	codec = q.Client.CodecForHeader(header, codec)
+	res, diagnostics := BuildTolerantTxsEventResponse(header.Time, txs, codec, nextKey)
+	return res, diagnostics, nil
+}
 
+// searchTxs runs the tx search of req over the CometBFT RPC, with the header of the block at height
+func searchTxs(ctx context.Context, q *Query, height int64, req *txTypes.GetTxsEventRequest) (*cmttypes.Header, *coretypes.ResultTxSearch, []byte, error) {
+	header, err := blockHeader(ctx, q, height)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	txs, nextKey, err := searchTxPage(ctx, q, req)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return header, txs, nextKey, nil
+}
+
+// searchTxPage runs the tx search of req over the CometBFT RPC
+func searchTxPage(ctx context.Context, q *Query, req *txTypes.GetTxsEventRequest) (*coretypes.ResultTxSearch, []byte, error) {
 	orderBy := ""
 	if req.OrderBy == txTypes.OrderBy_ORDER_BY_ASC {
 		orderBy = "asc"
@@ -77,14 +288,38 @@ func TxsRPC(q *Query, height int64, req *txTypes.GetTxsEventRequest, codec clien
 	page := int(req.Page)
 	perPage := int(req.Limit)
 
-	txs, err := q.Client.RPCClient.TxSearch(ctx, req.Query, false, &page, &perPage, orderBy)
+	var txs *coretypes.ResultTxSearch
+	err := q.Client.Retry(ctx, func(ctx context.Context) (err error) {
+		txs, err = q.Client.RPCClient.TxSearch(ctx, req.Query, false, &page, &perPage, orderBy)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return txs, utils.MakeNextKey(req.Page, req.Limit, uint64(txs.TotalCount)), nil
+}
+
+// blockHeader returns the header of the block at height, verified by the light client when one is configured
+func blockHeader(ctx context.Context, q *Query, height int64) (*cmttypes.Header, error) {
+	if err := q.Client.RequireRPC("block header"); err != nil {
+		return nil, err
+	}
+
+	var header *coretypes.ResultHeader
+	err := q.Client.Retry(ctx, func(ctx context.Context) (err error) {
+		header, err = q.Client.RPCClient.Header(ctx, &height)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	nextKey := utils.MakeNextKey(req.Page, req.Limit, uint64(txs.TotalCount))
+	if err := q.Client.VerifyHeader(ctx, header.Header); err != nil {
+		return nil, err
+	}
 
-	return BuildGetTxsEventResponse(timestamp, txs, codec.TxConfig.TxDecoder(), nextKey)
+	return header.Header, nil
 }
 
 func BuildGetTxsEventResponse(
@@ -108,33 +343,77 @@ func BuildGetTxsEventResponse(
 			return nil, err
 		}
 
-		raw := r.TxResult.Log
-		msgLogs, err := sdk.ParseABCILogs(raw)
-		if err != nil {
-			msgLogs = []sdk.ABCIMessageLog{{
-				MsgIndex: 0,
-				Log:      r.TxResult.Log,
-				Events:   sdk.StringifyEvents(r.TxResult.Events),
-			}}
+		txResp := buildTxResponse(timestamp, r, anyTx)
+		txResponses = append(txResponses, txResp)
+	}
+
+	return &txTypes.GetTxsEventResponse{
+		Txs:         txs,
+		TxResponses: txResponses,
+		Pagination: &query.PageResponse{
+			NextKey: nextKey,
+			Total:   uint64(results.TotalCount),
+		},
+		Total: uint64(results.TotalCount),
+	}, nil
+}
+
+// TxDiagnostic lists the decoding failures of a tx returned by the tolerant queries
+type TxDiagnostic struct {
+	Height int64
+	Index  uint32
+	TxHash string
+	Errors []error
+}
+
+// BuildTolerantTxsEventResponse is BuildGetTxsEventResponse with the tolerant decoding of
+// TxsRPCTolerant. Txs and TxResponses stay aligned, a tx that is not a tx at all is returned empty.
+// Unlike BuildGetTxsEventResponse, the tx responses also carry the events of the txs.
+func BuildTolerantTxsEventResponse(
+	timestamp time.Time,
+	results *coretypes.ResultTxSearch,
+	codec client.Codec,
+	nextKey []byte,
+) (*txTypes.GetTxsEventResponse, []TxDiagnostic) {
+
+	var diagnostics []TxDiagnostic
+	txs := make([]*txTypes.Tx, 0, len(results.Txs))
+	txResponses := make([]*sdk.TxResponse, 0, len(results.Txs))
+	for _, r := range results.Txs {
+		tx, errs := codec.DecodeTxTolerant(r.Tx)
+		if tx == nil {
+			tx = &txTypes.Tx{}
 		}
 
-		dataHex := hex.EncodeToString(r.TxResult.Data)
-
-		txResp := &sdk.TxResponse{
-			Height:    r.Height,
-			TxHash:    r.Hash.String(),
-			Codespace: r.TxResult.Codespace,
-			Code:      r.TxResult.Code,
-			Data:      dataHex,
-			RawLog:    r.TxResult.Log,
-			Logs:      msgLogs,
-			Info:      r.TxResult.Info,
-			GasWanted: r.TxResult.GasWanted,
-			GasUsed:   r.TxResult.GasUsed,
-			Tx:        anyTx,
-			Timestamp: timestamp.Format(time.RFC3339),
+		var anyTx *codectypes.Any
+		if len(errs) == 0 {
+			var err error
+			anyTx, err = codectypes.NewAnyWithValue(tx)
+			if err != nil {
+				errs = append(errs, err)
+			}
 		}
-		txResponses = append(txResponses, txResp)
+		if anyTx == nil {
+			// TxRaw is wire compatible with Tx, the raw bytes are kept as they are
+			anyTx = &codectypes.Any{TypeUrl: "/" + proto.MessageName(tx), Value: r.Tx}
+		}
+
+		if len(errs) > 0 {
+			logger.Warn("Failed to fully decode tx", "height", r.Height, "hash", r.Hash.String(), "errors", len(errs))
+			diagnostics = append(diagnostics, TxDiagnostic{
+				Height: r.Height,
+				Index:  r.Index,
+				TxHash: r.Hash.String(),
+				Errors: errs,
+			})
+		}
+
+		// The events are the only record of what an undecodable tx did, the tolerant responses keep them
+		txResponse := buildTxResponse(timestamp, r, anyTx)
+		txResponse.Events = r.TxResult.Events
+
+		txs = append(txs, tx)
+		txResponses = append(txResponses, txResponse)
 	}
 
 	return &txTypes.GetTxsEventResponse{
@@ -145,5 +424,34 @@ func BuildGetTxsEventResponse(
 			Total:   uint64(results.TotalCount),
 		},
 		Total: uint64(results.TotalCount),
-	}, nil
+	}, diagnostics
+}
+
+func buildTxResponse(timestamp time.Time, r *coretypes.ResultTx, anyTx *codectypes.Any) *sdk.TxResponse {
+	raw := r.TxResult.Log
+	msgLogs, err := sdk.ParseABCILogs(raw)
+	if err != nil {
+		msgLogs = []sdk.ABCIMessageLog{{
+			MsgIndex: 0,
+			Log:      r.TxResult.Log,
+			Events:   sdk.StringifyEvents(r.TxResult.Events),
+		}}
+	}
+
+	dataHex := hex.EncodeToString(r.TxResult.Data)
+
+	return &sdk.TxResponse{
+		Height:    r.Height,
+		TxHash:    r.Hash.String(),
+		Codespace: r.TxResult.Codespace,
+		Code:      r.TxResult.Code,
+		Data:      dataHex,
+		RawLog:    r.TxResult.Log,
+		Logs:      msgLogs,
+		Info:      r.TxResult.Info,
+		GasWanted: r.TxResult.GasWanted,
+		GasUsed:   r.TxResult.GasUsed,
+		Tx:        anyTx,
+		Timestamp: timestamp.Format(time.RFC3339),
+	}
 }
@@ -0,0 +1,101 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/RiemaLabs/probe/client"
+	"github.com/RiemaLabs/probe/logger"
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+	txTypes "github.com/cosmos/cosmos-sdk/types/tx"
+)
+
+// TxsFromBlockRPC returns the txs of the block at height like TxsAtHeightRPC, but builds them from
+// the block and its results instead of the tx search, so it works on nodes without tx indexing.
+// TxsAtHeightRPC falls back to it when the node reports that tx indexing is disabled.
+func TxsFromBlockRPC(q *Query, height int64, codec client.Codec) (*txTypes.GetTxsEventResponse, error) {
+	return TxsFromBlockRPCWithContext(context.Background(), q, height, codec)
+}
+
+// TxsFromBlockRPCWithContext is TxsFromBlockRPC bounded by ctx, every attempt is also bounded by the configured timeout
+func TxsFromBlockRPCWithContext(ctx context.Context, q *Query, height int64, codec client.Codec) (*txTypes.GetTxsEventResponse, error) {
+	header, txs, err := blockTxResults(ctx, q, height)
+	if err != nil {
+		return nil, err
+	}
+
+	codec = q.Client.CodecForHeader(header, codec)
+	return BuildGetTxsEventResponse(header.Time, txs, codec.TxConfig.TxDecoder(), nil)
+}
+
+// TxsFromBlockRPCTolerant is TxsFromBlockRPC with the tolerant decoding of TxsRPCTolerant
+func TxsFromBlockRPCTolerant(q *Query, height int64, codec client.Codec) (*txTypes.GetTxsEventResponse, []TxDiagnostic, error) {
+	return TxsFromBlockRPCTolerantWithContext(context.Background(), q, height, codec)
+}
+
+// TxsFromBlockRPCTolerantWithContext is TxsFromBlockRPCTolerant bounded by ctx
+func TxsFromBlockRPCTolerantWithContext(ctx context.Context, q *Query, height int64, codec client.Codec) (*txTypes.GetTxsEventResponse, []TxDiagnostic, error) {
+	header, txs, err := blockTxResults(ctx, q, height)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	codec = q.Client.CodecForHeader(header, codec)
+	res, diagnostics := BuildTolerantTxsEventResponse(header.Time, txs, codec, nil)
+	return res, diagnostics, nil
+}
+
+// blockTxResults zips the txs of the block at height with their results, in the shape of a tx search
+func blockTxResults(ctx context.Context, q *Query, height int64) (*cmttypes.Header, *coretypes.ResultTxSearch, error) {
+	if err := q.Client.RequireRPC("block txs"); err != nil {
+		return nil, nil, err
+	}
+
+	atHeight := &Query{Client: q.Client, Options: &QueryOptions{Height: height}}
+	if q.Options != nil {
+		options := *q.Options
+		options.Height = height
+		atHeight.Options = &options
+	}
+
+	block, err := BlockRPCWithContext(ctx, atHeight)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results, err := BlockResultsRPCWithContext(ctx, atHeight)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	blockTxs := block.Block.Data.Txs
+	if len(blockTxs) != len(results.TxsResults) {
+		return nil, nil, fmt.Errorf("block %d has %d txs but %d tx results", height, len(blockTxs), len(results.TxsResults))
+	}
+
+	txs := make([]*coretypes.ResultTx, 0, len(blockTxs))
+	for i, tx := range blockTxs {
+		txs = append(txs, &coretypes.ResultTx{
+			Hash:     tx.Hash(),
+			Height:   block.Block.Height,
+			Index:    uint32(i),
+			TxResult: *results.TxsResults[i],
+			Tx:       tx,
+		})
+	}
+
+	return &block.Block.Header, &coretypes.ResultTxSearch{Txs: txs, TotalCount: len(txs)}, nil
+}
+
+// isTxIndexingDisabled reports whether the tx search failed because the node doesn't index txs,
+// in which case the txs of a block can still be built from the block
+func isTxIndexingDisabled(q *Query, err error) bool {
+	if q.Client.RPCClient == nil || !errors.Is(client.ClassifyError(err), client.ErrTxIndexingDisabled) {
+		return false
+	}
+
+	logger.Debug("Tx indexing is disabled on the node, building the txs from the block")
+	return true
+}
@@ -0,0 +1,131 @@
+package query
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/RiemaLabs/probe/utils"
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	txTypes "github.com/cosmos/cosmos-sdk/types/tx"
+)
+
+// MaxConcurrentTxLookups bounds the lookups run at once by TxsByHashRPC
+var MaxConcurrentTxLookups = 8
+
+// TxByHashRPC returns the tx with the given hex encoded hash, decoded with the codec matching its
+// block, and its TxResponse in the shape built by BuildGetTxsEventResponse.
+//
+// With prove, the inclusion proof returned by the node is checked against the data hash of the
+// block header, itself verified by the light client when one is configured. Without prove, the
+// app gRPC server is used when the gRPC transport is configured.
+func TxByHashRPC(q *Query, hash string, prove bool) (*txTypes.GetTxResponse, error) {
+	return TxByHashRPCWithContext(context.Background(), q, hash, prove)
+}
+
+// TxByHashRPCWithContext is TxByHashRPC bounded by ctx, every attempt is also bounded by the configured timeout
+func TxByHashRPCWithContext(ctx context.Context, q *Query, hash string, prove bool) (*txTypes.GetTxResponse, error) {
+	hashBytes, err := parseTxHash(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if q.Client.UsesGRPC() && !prove {
+		return txTypes.NewServiceClient(q.Client).GetTx(ctx, &txTypes.GetTxRequest{Hash: hex.EncodeToString(hashBytes)})
+	}
+
+	// The grpc transport serves the lookups without proof
+	if err := q.Client.RequireRPC("tx lookup by hash with proof"); err != nil {
+		return nil, err
+	}
+
+	var res *coretypes.ResultTx
+	err = q.Client.Retry(ctx, func(ctx context.Context) (err error) {
+		res, err = q.Client.RPCClient.Tx(ctx, hashBytes, prove)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := blockHeader(ctx, q, res.Height)
+	if err != nil {
+		return nil, err
+	}
+
+	if prove {
+		if err := verifyTxProof(res, header); err != nil {
+			return nil, err
+		}
+	}
+
+	codec := q.Client.CodecForHeader(header, q.Client.Codec)
+	tx, err := utils.TxBytesToProto(res.Tx, codec.TxConfig.TxDecoder())
+	if err != nil {
+		return nil, err
+	}
+
+	anyTx, err := codectypes.NewAnyWithValue(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &txTypes.GetTxResponse{
+		Tx:         tx,
+		TxResponse: buildTxResponse(header.Time, res, anyTx),
+	}, nil
+}
+
+// TxsByHashRPC is TxByHashRPC for many hashes, looked up concurrently. The results are in the order
+// of the hashes, the first failure fails the batch.
+func TxsByHashRPC(q *Query, hashes []string, prove bool) ([]*txTypes.GetTxResponse, error) {
+	return TxsByHashRPCWithContext(context.Background(), q, hashes, prove)
+}
+
+// TxsByHashRPCWithContext is TxsByHashRPC bounded by ctx
+func TxsByHashRPCWithContext(ctx context.Context, q *Query, hashes []string, prove bool) ([]*txTypes.GetTxResponse, error) {
+	results := make([]*txTypes.GetTxResponse, len(hashes))
+	err := runConcurrently(ctx, len(hashes), MaxConcurrentTxLookups, func(ctx context.Context, i int) error {
+		res, err := TxByHashRPCWithContext(ctx, q, hashes[i], prove)
+		if err != nil {
+			return fmt.Errorf("tx %s: %w", hashes[i], err)
+		}
+		results[i] = res
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// parseTxHash decodes a hex encoded tx hash, with or without 0x prefix
+func parseTxHash(hash string) ([]byte, error) {
+	hashBytes, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(hash, "0x"), "0X"))
+	if err != nil || len(hashBytes) != 32 {
+		return nil, fmt.Errorf("invalid tx hash %q, expected 32 hex encoded bytes", hash)
+	}
+	return hashBytes, nil
+}
+
+// verifyTxProof checks that the tx is included in the data of the block of the header
+func verifyTxProof(res *coretypes.ResultTx, header *cmttypes.Header) error {
+	if !bytes.Equal(res.Proof.RootHash, header.DataHash) {
+		return fmt.Errorf("tx proof of %X has root %X, the block data hash is %X", res.Hash, res.Proof.RootHash, header.DataHash)
+	}
+
+	if err := res.Proof.Validate(header.DataHash); err != nil {
+		return fmt.Errorf("invalid tx proof of %X: %w", res.Hash, err)
+	}
+
+	if !bytes.Equal(res.Proof.Data, res.Tx) {
+		return fmt.Errorf("tx proof of %X proves other data", res.Hash)
+	}
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/RiemaLabs/probe/client"
+	"github.com/RiemaLabs/probe/logger"
+	cmttypes "github.com/cometbft/cometbft/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// DefaultWaitForTxTimeout bounds WaitForTxRPC when ctx has no deadline
+const DefaultWaitForTxTimeout = time.Minute
+
+// TxTimeoutError is returned by WaitForTxRPC when the tx was not included before the deadline.
+// It matches client.ErrTimeout with errors.Is.
+type TxTimeoutError struct {
+	TxHash string
+	Waited time.Duration
+}
+
+func (e *TxTimeoutError) Error() string {
+	return fmt.Sprintf("tx %s not included after %s", e.TxHash, e.Waited)
+}
+
+func (e *TxTimeoutError) Is(target error) bool {
+	return target == client.ErrTimeout
+}
+
+// TxFailedError is returned by WaitForTxRPC when the tx was included with a non-zero code.
+// It unwraps to a client.ABCIError, so it matches client.ErrABCICode with errors.Is.
+type TxFailedError struct {
+	TxResponse *sdk.TxResponse
+}
+
+func (e *TxFailedError) Error() string {
+	res := e.TxResponse
+	return fmt.Sprintf("tx %s failed at height %d (codespace: %s, code: %d): %s", res.TxHash, res.Height, res.Codespace, res.Code, res.RawLog)
+}
+
+func (e *TxFailedError) Unwrap() error {
+	return &client.ABCIError{
+		Codespace: e.TxResponse.Codespace,
+		Code:      e.TxResponse.Code,
+		Log:       e.TxResponse.RawLog,
+	}
+}
+
+// WaitForTxRPC waits until the tx with the given hex encoded hash is included in a block and
+// returns its response. The inclusion is observed through a websocket subscription when the node
+// accepts one, otherwise /tx is polled with the backoff of the retry policy. Lookups failing with
+// a transient error, see client.IsTransient, don't end the wait.
+//
+// A tx included with a non-zero code is reported as a TxFailedError, and a tx not included before
+// the deadline of ctx, or DefaultWaitForTxTimeout without one, as a TxTimeoutError.
+func WaitForTxRPC(ctx context.Context, q *Query, hash string) (*sdk.TxResponse, error) {
+	hashBytes, err := parseTxHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	hash = fmt.Sprintf("%X", hashBytes)
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultWaitForTxTimeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	res, err := waitForTx(ctx, q, hash)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil {
+			return nil, &TxTimeoutError{TxHash: hash, Waited: time.Since(start).Round(time.Millisecond)}
+		}
+		return nil, err
+	}
+
+	if res.Code != 0 {
+		return nil, &TxFailedError{TxResponse: res}
+	}
+
+	return res, nil
+}
+
+func waitForTx(ctx context.Context, q *Query, hash string) (*sdk.TxResponse, error) {
+	if q.Client.RPCClient != nil {
+		sub, err := q.Client.Subscribe(ctx, fmt.Sprintf("%s AND %s='%s'", cmttypes.EventQueryTx, cmttypes.TxHashKey, hash), 1)
+		if err != nil {
+			logger.Debug("Tx subscription unavailable, polling", "hash", hash, "error", err.Error())
+		} else {
+			defer sub.Close()
+
+			// The tx may have been included before the subscription was made
+			if res, err := lookupTx(ctx, q, hash, true); res != nil || err != nil {
+				return res, err
+			}
+
+			if res, err := waitForTxEvent(ctx, q, sub); res != nil || err != nil {
+				return res, err
+			}
+			logger.Warn("Tx subscription ended, polling", "hash", hash)
+		}
+	}
+
+	policy := client.RetryPolicy{}
+	if q.Client.Config.Retry != nil {
+		policy = *q.Client.Config.Retry
+	}
+
+	for attempt := 1; ; attempt++ {
+		if res, err := lookupTx(ctx, q, hash, false); res != nil || err != nil {
+			return res, err
+		}
+
+		timer := time.NewTimer(policy.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// waitForTxEvent returns the first tx delivered by sub, or nothing when sub ended before
+func waitForTxEvent(ctx context.Context, q *Query, sub *client.Subscription) (*sdk.TxResponse, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case event, ok := <-sub.Events():
+			if !ok {
+				return nil, nil
+			}
+
+			data, ok := event.Data.(cmttypes.EventDataTx)
+			if !ok {
+				logger.Warn("Unexpected tx event", "query", event.Query)
+				continue
+			}
+
+			header, err := blockHeader(ctx, q, data.Height)
+			if err != nil {
+				return nil, err
+			}
+
+			txEvent, err := decodeTxEvent(q, header, data.TxResult)
+			if err != nil {
+				return nil, err
+			}
+			return txEvent.TxResponse, nil
+		}
+	}
+}
+
+// lookupTx returns the response of the tx, or nothing when it is not included yet or the lookup
+// failed with a transient error. While subscribed, a node without tx indexing also returns nothing,
+// the tx is then delivered by the subscription.
+func lookupTx(ctx context.Context, q *Query, hash string, subscribed bool) (*sdk.TxResponse, error) {
+	res, err := TxByHashRPCWithContext(ctx, q, hash, false)
+	if err == nil {
+		return res.TxResponse, nil
+	}
+
+	err = client.ClassifyError(err)
+	switch {
+	case errors.Is(err, client.ErrNotFound):
+		return nil, nil
+	case subscribed && errors.Is(err, client.ErrTxIndexingDisabled):
+		return nil, nil
+	case ctx.Err() == nil && client.IsTransient(err):
+		logger.Debug("Tx lookup failed, waiting", "hash", hash, "error", err.Error())
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
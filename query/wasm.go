@@ -2,12 +2,17 @@ package query
 
 import (
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"math/big"
 	"time"
 
+	wasmtypes "github.com/CosmWasm/wasmd/x/wasm/types"
 	"github.com/RiemaLabs/probe/client"
+	"github.com/RiemaLabs/probe/logger"
+	abci "github.com/cometbft/cometbft/abci/types"
+	"github.com/cosmos/cosmos-sdk/types/bech32"
 	"github.com/gogo/protobuf/proto"
 )
 
@@ -32,6 +37,11 @@ func (*SmartContractStateResponse) ProtoMessage()    {}
 
 type WasmQueryOptions struct {
 	ContractAddress string
+	// Height pins the contract queries to a block height, 0 queries the latest state
+	Height int64
+	// RequireVerified rejects the smart queries when Config.VerifyProofs is set, instead of
+	// returning their results unverified
+	RequireVerified bool
 }
 
 type WasmQuery struct {
@@ -39,15 +49,45 @@ type WasmQuery struct {
 	Options *WasmQueryOptions
 }
 
+// GetQueryContext returns a context that includes the height and uses the timeout from the config
+//
+// Deprecated: the query functions take a context, use HeightContext to pin it to the height
 func (q *WasmQuery) GetQueryContext() (context.Context, context.CancelFunc) {
-	timeout, _ := time.ParseDuration(q.Client.Config.Timeout) // Timeout is validated in the config so no error check
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	return ctx, cancel
+	ctx := q.HeightContext(context.Background())
+
+	// NewChainClient validates the timeout, a config changed afterwards gets a context without deadline
+	// rather than one that is already expired
+	timeout, err := time.ParseDuration(q.Client.Config.Timeout)
+	if err != nil || timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// HeightContext pins the gRPC contract queries made with the returned context to Options.Height
+func (q *WasmQuery) HeightContext(ctx context.Context) context.Context {
+	if q.Options == nil {
+		return ctx
+	}
+	return client.SetHeightOnContext(ctx, q.Options.Height)
 }
 
 func (q *WasmQuery) QueryContractState(msg []byte) ([]byte, error) {
-	ctx, cancel := q.GetQueryContext()
-	defer cancel()
+	return q.QueryContractStateWithContext(context.Background(), msg)
+}
+
+// QueryContractStateWithContext is QueryContractState bounded by ctx, every attempt is also bounded by the configured timeout.
+//
+// Smart queries execute the contract, their result is not stored and has no merkle proof. When
+// Config.VerifyProofs is set, the result is returned unverified and a warning is logged, unless
+// Options.RequireVerified rejects the query. QueryRawContractState reads verified contract state.
+func (q *WasmQuery) QueryContractStateWithContext(ctx context.Context, msg []byte) ([]byte, error) {
+	if q.Client.Config.VerifyProofs {
+		if q.Options.RequireVerified {
+			return nil, fmt.Errorf("%w: smart query results cannot be proven, use QueryRawContractState", client.ErrProofVerification)
+		}
+		logger.Warn("Smart query results cannot be proven, returning an unverified result", "contract", q.Options.ContractAddress)
+	}
 
 	// Create the protobuf request
 	queryRequest := &SmartContractStateRequest{
@@ -55,6 +95,16 @@ func (q *WasmQuery) QueryContractState(msg []byte) ([]byte, error) {
 		QueryData: msg,
 	}
 
+	// Module queries go to the app gRPC server when that transport is configured
+	if q.Client.UsesGRPC() {
+		ctx = q.HeightContext(ctx)
+		var response SmartContractStateResponse
+		if err := q.Client.Invoke(ctx, "/cosmwasm.wasm.v1.Query/SmartContractState", queryRequest, &response); err != nil {
+			return nil, fmt.Errorf("failed to query contract state: %w", err)
+		}
+		return response.Data, nil
+	}
+
 	// Serialize using protobuf
 	queryMsgBytes, err := proto.Marshal(queryRequest)
 	if err != nil {
@@ -62,26 +112,54 @@ func (q *WasmQuery) QueryContractState(msg []byte) ([]byte, error) {
 	}
 
 	// Use the correct ABCI query path for smart contract queries
-	res, err := q.Client.RPCClient.ABCIQuery(ctx, "/cosmwasm.wasm.v1.Query/SmartContractState", queryMsgBytes)
+	res, err := q.Client.QueryABCI(ctx, abci.RequestQuery{
+		Path:   "/cosmwasm.wasm.v1.Query/SmartContractState",
+		Data:   queryMsgBytes,
+		Height: q.Options.Height,
+	})
 	if err != nil {
-		return nil, err
-	}
-	if res.Response.Code != 0 {
-		return nil, fmt.Errorf("failed to query contract state: %s", res.Response.Log)
+		return nil, fmt.Errorf("failed to query contract state: %w", err)
 	}
 
 	// Unmarshal the protobuf response
 	var response SmartContractStateResponse
-	if err := proto.Unmarshal(res.Response.Value, &response); err != nil {
+	if err := proto.Unmarshal(res.Value, &response); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal contract state response: %w", err)
 	}
 
 	return response.Data, nil
 }
 
+// QueryRawContractState reads a key of the contract storage, the value is nil when the key is not set.
+// The value is checked against its merkle proof when proofs are verified.
+func (q *WasmQuery) QueryRawContractState(key []byte) ([]byte, error) {
+	return q.QueryRawContractStateWithContext(context.Background(), key)
+}
+
+// QueryRawContractStateWithContext is QueryRawContractState bounded by ctx
+func (q *WasmQuery) QueryRawContractStateWithContext(ctx context.Context, key []byte) ([]byte, error) {
+	_, addr, err := bech32.DecodeAndConvert(q.Options.ContractAddress)
+	if err != nil {
+		return nil, fmt.Errorf("invalid contract address %s: %w", q.Options.ContractAddress, err)
+	}
+
+	storeKey := append(wasmtypes.GetContractStorePrefix(addr), key...)
+	return q.Client.QueryStore(ctx, wasmtypes.StoreKey, storeKey, q.Options.Height)
+}
+
 func (q *WasmQuery) QueryCw20Balance(address string) (*big.Int, error) {
+	return q.QueryCw20BalanceWithContext(context.Background(), address)
+}
+
+// QueryCw20BalanceWithContext is QueryCw20Balance bounded by ctx. When Config.VerifyProofs is set,
+// the balance is read from the verified storage of the contract, which must follow the cw20-base layout.
+func (q *WasmQuery) QueryCw20BalanceWithContext(ctx context.Context, address string) (*big.Int, error) {
+	if q.Client.Config.VerifyProofs {
+		return q.queryCw20BalanceRaw(ctx, address)
+	}
+
 	queryMsg := []byte(fmt.Sprintf(`{"balance":{"address":"%s"}}`, address))
-	res, err := q.QueryContractState(queryMsg)
+	res, err := q.QueryContractStateWithContext(ctx, queryMsg)
 	if err != nil {
 		return nil, err
 	}
@@ -101,3 +179,39 @@ func (q *WasmQuery) QueryCw20Balance(address string) (*big.Int, error) {
 
 	return balance, nil
 }
+
+// cw20BalancesNamespace is the storage namespace of the balances map of cw20-base
+const cw20BalancesNamespace = "balance"
+
+// Cw20BalanceKey returns the storage key of the balance of address in a cw20-base contract, i.e.
+// the key of address in the cw-storage-plus Map named "balance"
+func Cw20BalanceKey(address string) []byte {
+	key := make([]byte, 0, 2+len(cw20BalancesNamespace)+len(address))
+	key = binary.BigEndian.AppendUint16(key, uint16(len(cw20BalancesNamespace)))
+	key = append(key, cw20BalancesNamespace...)
+	return append(key, address...)
+}
+
+func (q *WasmQuery) queryCw20BalanceRaw(ctx context.Context, address string) (*big.Int, error) {
+	value, err := q.QueryRawContractStateWithContext(ctx, Cw20BalanceKey(address))
+	if err != nil {
+		return nil, err
+	}
+
+	// An address that never held tokens has no balance entry
+	balance := new(big.Int)
+	if value == nil {
+		return balance, nil
+	}
+
+	// Balances are stored as JSON encoded Uint128 strings
+	var amount string
+	if err := json.Unmarshal(value, &amount); err != nil {
+		return nil, fmt.Errorf("failed to parse stored balance: %w", err)
+	}
+	if _, ok := balance.SetString(amount, 10); !ok {
+		return nil, fmt.Errorf("invalid stored balance %q", amount)
+	}
+
+	return balance, nil
+}
@@ -0,0 +1,104 @@
+package test
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/RiemaLabs/probe/client"
+	probeCodecTypes "github.com/RiemaLabs/probe/client/codec/types"
+	querier "github.com/RiemaLabs/probe/query"
+	cmtversion "github.com/cometbft/cometbft/proto/tendermint/version"
+	cmttypes "github.com/cometbft/cometbft/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newScheduledChain starts a fake RPC serving a block of MsgPing txs at height 42, and a client
+// with the codec schedule
+func newScheduledChain(t *testing.T, schedule ...client.CodecUpgrade) (*fakeRPC, *client.ChainClient) {
+	fake := &fakeRPC{height: 42, time: time.Unix(1700000000, 0).UTC()}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	cl, err := client.NewChainClient(&client.ChainClientConfig{
+		ChainID:               fakeChainID,
+		RPCAddr:               server.URL,
+		Timeout:               "10s",
+		OutputFormat:          "json",
+		CodecSchedule:         schedule,
+		Modules:               client.DefaultModuleBasics,
+		CustomMsgTypeRegistry: client.DefaultCustomMsgTypeRegistry,
+	})
+	require.NoError(t, err, "Failed to create chain client")
+
+	fake.txs = []cmttypes.Tx{pingTx(t, cl.Codec, pingDescriptorSet())}
+	return fake, cl
+}
+
+func decodesPing(codec client.Codec, tx []byte) bool {
+	decoded, err := codec.TxConfig.TxDecoder()(tx)
+	if err != nil {
+		return false
+	}
+	_, ok := decoded.GetMsgs()[0].(*probeCodecTypes.DynamicMsg)
+	return ok
+}
+
+func TestCodecForHeader(t *testing.T) {
+	path := writePingDescriptorSet(t)
+	fake, cl := newScheduledChain(t,
+		client.CodecUpgrade{FromHeight: 200},
+		client.CodecUpgrade{FromHeight: 100, DescriptorSets: []string{path}},
+		client.CodecUpgrade{AppVersion: 7, DescriptorSets: []string{path}},
+	)
+	tx := fake.txs[0]
+
+	tests := []struct {
+		name       string
+		height     int64
+		appVersion uint64
+		ping       bool
+	}{
+		{"before the schedule", 99, 0, false},
+		{"at the upgrade height", 100, 0, true},
+		{"before the next upgrade", 199, 0, true},
+		{"at the next upgrade", 200, 0, false},
+		{"matching app version", 250, 7, true},
+		{"other app version", 250, 6, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := &cmttypes.Header{Height: tt.height, Version: cmtversion.Consensus{App: tt.appVersion}}
+			codec := cl.CodecForHeader(header, cl.Codec)
+			assert.Equal(t, tt.ping, decodesPing(codec, tx))
+		})
+	}
+
+	assert.False(t, decodesPing(cl.CodecForHeader(nil, cl.Codec), tx), "A missing header should use the fallback")
+}
+
+func TestTxByHashUsesScheduledCodec(t *testing.T) {
+	path := writePingDescriptorSet(t)
+
+	fake, cl := newScheduledChain(t, client.CodecUpgrade{FromHeight: 42, DescriptorSets: []string{path}})
+	query := querier.Query{Client: cl, Options: &querier.QueryOptions{}}
+	hash := hex.EncodeToString(fake.txs[0].Hash())
+
+	res, err := querier.TxByHashRPC(&query, hash, false)
+	require.NoError(t, err, "The tx should be decoded with the codec of its height")
+	require.Len(t, res.Tx.Body.Messages, 1)
+	assert.Equal(t, pingTypeURL, res.Tx.Body.Messages[0].TypeUrl)
+
+	codec, err := cl.CodecAt(context.Background(), fake.height, cl.Codec)
+	require.NoError(t, err)
+	assert.True(t, decodesPing(codec, fake.txs[0]))
+
+	fake, cl = newScheduledChain(t, client.CodecUpgrade{FromHeight: 43, DescriptorSets: []string{path}})
+	query = querier.Query{Client: cl, Options: &querier.QueryOptions{}}
+	_, err = querier.TxByHashRPC(&query, hex.EncodeToString(fake.txs[0].Hash()), false)
+	assert.Error(t, err, "A tx before the upgrade should be decoded with the default codec")
+}
@@ -0,0 +1,67 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/RiemaLabs/probe/client"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	"github.com/cosmos/cosmos-sdk/crypto/keys/taproot"
+	"github.com/cosmos/cosmos-sdk/types/tx/signing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	txTypes "github.com/cosmos/cosmos-sdk/types/tx"
+)
+
+// taprootSignedTx encodes a tx whose signer public key is a taproot key packed under the type URL
+// of the PubKey interface, as the chain does
+func taprootSignedTx(t *testing.T, cdc client.Codec, pubKey *taproot.PubKey) []byte {
+	keyBytes, err := pubKey.Marshal()
+	require.NoError(t, err, "Failed to marshal public key")
+
+	body, err := cdc.Marshaler.Marshal(&txTypes.TxBody{Memo: "taproot"})
+	require.NoError(t, err, "Failed to marshal tx body")
+
+	authInfo, err := cdc.Marshaler.Marshal(&txTypes.AuthInfo{
+		SignerInfos: []*txTypes.SignerInfo{{
+			PublicKey: &codectypes.Any{TypeUrl: "/cosmos.crypto.PubKey", Value: keyBytes},
+			ModeInfo:  &txTypes.ModeInfo{Sum: &txTypes.ModeInfo_Single_{Single: &txTypes.ModeInfo_Single{Mode: signing.SignMode_SIGN_MODE_DIRECT}}},
+			Sequence:  7,
+		}},
+		Fee: &txTypes.Fee{GasLimit: 200000},
+	})
+	require.NoError(t, err, "Failed to marshal auth info")
+
+	raw, err := cdc.Marshaler.Marshal(&txTypes.TxRaw{BodyBytes: body, AuthInfoBytes: authInfo, Signatures: [][]byte{make([]byte, 64)}})
+	require.NoError(t, err, "Failed to marshal tx")
+	return raw
+}
+
+// requireTaprootSigner checks that cdc decodes the signer public key of a taproot signed tx
+func requireTaprootSigner(t *testing.T, cdc client.Codec, pubKey *taproot.PubKey) {
+	tx, err := cdc.TxConfig.TxDecoder()(taprootSignedTx(t, cdc, pubKey))
+	require.NoError(t, err, "Failed to decode taproot signed tx")
+
+	wrapper, ok := tx.(interface{ GetProtoTx() *txTypes.Tx })
+	require.True(t, ok, "Decoded tx should wrap a proto tx")
+	signer := wrapper.GetProtoTx().AuthInfo.SignerInfos[0]
+	assert.Equal(t, uint64(7), signer.Sequence)
+
+	decoded, ok := signer.PublicKey.GetCachedValue().(*taproot.PubKey)
+	require.True(t, ok, "The signer public key should resolve to a taproot key, got %T", signer.PublicKey.GetCachedValue())
+	assert.True(t, decoded.Equals(pubKey))
+}
+
+func TestMakeCodecRegistersTaprootKeys(t *testing.T) {
+	cdc, err := client.MakeCodec(client.DefaultModuleBasics, client.DefaultCustomMsgTypeRegistry)
+	require.NoError(t, err, "Failed to make codec")
+
+	requireTaprootSigner(t, cdc, taproot.GenPrivKey().PubKey().(*taproot.PubKey))
+}
+
+func TestNewChainClientDefaultsCustomTypeRegistry(t *testing.T) {
+	_, cl := newFakeChain(t, 0, false)
+	require.Nil(t, cl.Config.CustomTypeRegistry, "The config should not set a custom type registry")
+
+	requireTaprootSigner(t, cl.Codec, taproot.GenPrivKey().PubKey().(*taproot.PubKey))
+}
@@ -0,0 +1,132 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/RiemaLabs/probe/client"
+	probeCodecTypes "github.com/RiemaLabs/probe/client/codec/types"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	authtx "github.com/cosmos/cosmos-sdk/x/auth/tx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	txTypes "github.com/cosmos/cosmos-sdk/types/tx"
+)
+
+const pingTypeURL = "/probe.test.v1.MsgPing"
+
+// pingDescriptorSet describes a module unknown to the codec, with a Msg service taking MsgPing
+func pingDescriptorSet() *descriptorpb.FileDescriptorSet {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     protov2.String(name),
+			JsonName: protov2.String(name),
+			Number:   protov2.Int32(number),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     typ.Enum(),
+		}
+	}
+
+	return &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:    protov2.String("probe/test/v1/tx.proto"),
+		Package: protov2.String("probe.test.v1"),
+		Syntax:  protov2.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: protov2.String("MsgPing"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("sender", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+				field("count", 2, descriptorpb.FieldDescriptorProto_TYPE_UINT64),
+			}},
+			{Name: protov2.String("MsgPingResponse")},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: protov2.String("Msg"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       protov2.String("Ping"),
+				InputType:  protov2.String(".probe.test.v1.MsgPing"),
+				OutputType: protov2.String(".probe.test.v1.MsgPingResponse"),
+			}},
+		}},
+	}}}
+}
+
+// writePingDescriptorSet writes the descriptor set of MsgPing to a file
+func writePingDescriptorSet(t *testing.T) string {
+	bz, err := protov2.Marshal(pingDescriptorSet())
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "ping.binpb")
+	require.NoError(t, os.WriteFile(path, bz, 0o600))
+	return path
+}
+
+// pingTx encodes a tx holding a MsgPing, built from its descriptor only
+func pingTx(t *testing.T, cdc client.Codec, fds *descriptorpb.FileDescriptorSet) []byte {
+	files, err := protodesc.NewFiles(fds)
+	require.NoError(t, err)
+	desc, err := files.FindDescriptorByName("probe.test.v1.MsgPing")
+	require.NoError(t, err)
+
+	msgDesc := desc.(protoreflect.MessageDescriptor)
+	ping := dynamicpb.NewMessage(msgDesc)
+	ping.Set(msgDesc.Fields().ByName("sender"), protoreflect.ValueOfString("bc1pholder"))
+	ping.Set(msgDesc.Fields().ByName("count"), protoreflect.ValueOfUint64(3))
+	value, err := protov2.Marshal(ping)
+	require.NoError(t, err)
+
+	body, err := cdc.Marshaler.Marshal(&txTypes.TxBody{Messages: []*codectypes.Any{{TypeUrl: pingTypeURL, Value: value}}, Memo: "ping"})
+	require.NoError(t, err)
+	authInfo, err := cdc.Marshaler.Marshal(&txTypes.AuthInfo{Fee: &txTypes.Fee{GasLimit: 200000}})
+	require.NoError(t, err)
+	raw, err := cdc.Marshaler.Marshal(&txTypes.TxRaw{BodyBytes: body, AuthInfoBytes: authInfo, Signatures: [][]byte{{1, 2, 3}}})
+	require.NoError(t, err)
+	return raw
+}
+
+func TestDecodeTxWithDynamicMsg(t *testing.T) {
+	fds := pingDescriptorSet()
+	path := writePingDescriptorSet(t)
+
+	cdc, err := client.MakeCodec(client.DefaultModuleBasics, client.DefaultCustomMsgTypeRegistry)
+	require.NoError(t, err, "Failed to make codec")
+	raw := pingTx(t, cdc, fds)
+
+	_, err = cdc.TxConfig.TxDecoder()(raw)
+	require.Error(t, err, "A message without descriptor should not be decoded")
+
+	require.NoError(t, cdc.RegisterDescriptorSets(path))
+	assert.True(t, cdc.ProbeInterfaceRegistry.TypeURLIsRegistered(pingTypeURL))
+
+	_, err = authtx.NewTxConfig(cdc.Marshaler, authtx.DefaultSignModes).TxDecoder()(raw)
+	require.Error(t, err, "The sdk decoder should reject dynamic messages, for the fallback to be used")
+
+	decoded, err := cdc.TxConfig.TxDecoder()(raw)
+	require.NoError(t, err, "Failed to decode tx with a dynamic message")
+
+	msgs := decoded.GetMsgs()
+	require.Len(t, msgs, 1)
+	ping, ok := msgs[0].(*probeCodecTypes.DynamicMsg)
+	require.True(t, ok, "The message should be decoded from its descriptor, got %T", msgs[0])
+	fields := ping.Descriptor().Fields()
+	assert.Equal(t, "bc1pholder", ping.Get(fields.ByName("sender")).String())
+	assert.Equal(t, uint64(3), ping.Get(fields.ByName("count")).Uint())
+
+	protoTx := decoded.(interface{ GetProtoTx() *txTypes.Tx }).GetProtoTx()
+	assert.Equal(t, "ping", protoTx.Body.Memo)
+
+	// Encoding the decoded tx again gives the same bytes
+	encoded, err := cdc.TxConfig.TxEncoder()(decoded)
+	require.NoError(t, err, "Failed to encode tx with a dynamic message")
+	assert.Equal(t, raw, encoded)
+
+	json, err := cdc.Marshaler.MarshalJSON(protoTx)
+	require.NoError(t, err, "Failed to render tx with a dynamic message")
+	assert.Contains(t, string(json), `"@type":"/probe.test.v1.MsgPing"`)
+	assert.Contains(t, string(json), `"sender":"bc1pholder"`)
+	assert.Contains(t, string(json), `"count":"3"`)
+}
@@ -0,0 +1,123 @@
+package test
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"syscall"
+	"testing"
+
+	"github.com/RiemaLabs/probe/client"
+	legacyerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		kind error
+	}{
+		{"tx not found", errors.New("tx (5C7E2A6B0F0D8F9E) not found"), client.ErrNotFound},
+		{"sdk tx not found", errors.New("rpc error: code = Unknown desc = tx not found: 5C7E"), client.ErrNotFound},
+		{"grpc not found", status.Error(codes.NotFound, "account bc1p not found"), client.ErrNotFound},
+		{"abci key not found", &client.ABCIError{Codespace: legacyerrors.ErrKeyNotFound.Codespace(), Code: legacyerrors.ErrKeyNotFound.ABCICode()}, client.ErrNotFound},
+		{"future height", errors.New("height 120 must be less than or equal to the current blockchain height 100"), client.ErrHeightNotAvailable},
+		{"future query height", errors.New("cannot query with height in the future; please provide a valid height"), client.ErrHeightNotAvailable},
+		{"pruned height", errors.New("height 5 is not available, lowest height is 100"), client.ErrPruned},
+		{"pruned state", errors.New("failed to load state at height 5; version does not exist"), client.ErrPruned},
+		{"tx indexing disabled", errors.New("transaction indexing is disabled"), client.ErrTxIndexingDisabled},
+		{"deadline exceeded", fmt.Errorf("post failed: %w", context.DeadlineExceeded), client.ErrTimeout},
+		{"grpc deadline exceeded", status.Error(codes.DeadlineExceeded, "deadline"), client.ErrTimeout},
+		{"http client timeout", errors.New("Post \"http://node\": Client.Timeout exceeded while awaiting headers"), client.ErrTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := client.ClassifyError(tt.err)
+			assert.ErrorIs(t, err, tt.kind)
+			assert.ErrorIs(t, err, tt.err, "The classified error should wrap the original one")
+		})
+	}
+}
+
+func TestClassifyErrorUnclassified(t *testing.T) {
+	for _, msg := range []string{
+		"key not found in store",
+		"account bc1pxyz not found",
+		"codec not found for type url",
+		"contract: not found",
+		"invalid request",
+	} {
+		t.Run(msg, func(t *testing.T) {
+			err := errors.New(msg)
+			assert.Same(t, err, client.ClassifyError(err), "Unknown errors should be returned unchanged")
+		})
+	}
+
+	assert.NoError(t, client.ClassifyError(nil))
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{"nil", nil, false},
+		{"timeout", client.ClassifyError(context.DeadlineExceeded), true},
+		{"canceled", context.Canceled, false},
+		{"eof", fmt.Errorf("read: %w", io.EOF), true},
+		{"unexpected eof", io.ErrUnexpectedEOF, true},
+		{"connection refused", errors.New("dial tcp 127.0.0.1:26657: connect: connection refused"), true},
+		{"service unavailable", errors.New("error in json rpc client, with http response metadata: (Status: 503 Service Unavailable)"), true},
+		{"grpc unavailable", status.Error(codes.Unavailable, "node restarting"), true},
+		{"grpc resource exhausted", status.Error(codes.ResourceExhausted, "rate limited"), true},
+		{"eof in message", errors.New("failed to decode msg: unexpected field EOF_MARKER"), false},
+		{"height not available", client.ClassifyError(errors.New("height 120 must be less than or equal to the current blockchain height 100")), false},
+		{"pruned", client.ClassifyError(errors.New("failed to load state at height 5")), false},
+		{"not found", client.ClassifyError(errors.New("tx (AB) not found")), false},
+		{"tx indexing disabled", client.ClassifyError(errors.New("transaction indexing is disabled")), false},
+		{"abci error", &client.ABCIError{Codespace: "sdk", Code: 5, Log: "insufficient funds"}, false},
+		{"grpc invalid argument", status.Error(codes.InvalidArgument, "bad address"), false},
+		{"url connection refused", &url.Error{Op: "Post", URL: "http://127.0.0.1:26657", Err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}, true},
+		{"url timeout", &url.Error{Op: "Post", URL: "http://127.0.0.1:26657", Err: &net.DNSError{Err: "i/o timeout", IsTimeout: true}}, true},
+		{"url x509", &url.Error{Op: "Post", URL: "https://node.example", Err: x509.UnknownAuthorityError{}}, false},
+		{"url unknown host", &url.Error{Op: "Post", URL: "https://node.invalid", Err: &net.DNSError{Err: "no such host", Name: "node.invalid", IsNotFound: true}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.transient, client.IsTransient(tt.err))
+		})
+	}
+}
+
+func TestRetryHeightNotAvailable(t *testing.T) {
+	_, cl := newFakeChain(t, 0, false)
+	future := errors.New("height 120 must be less than or equal to the current blockchain height 100")
+
+	attempts := 0
+	op := func(ctx context.Context) error {
+		attempts++
+		return future
+	}
+
+	cl.Config.Retry = &client.RetryPolicy{MaxAttempts: 3, InitialBackoff: "1ms"}
+	err := cl.Retry(context.Background(), op)
+	require.ErrorIs(t, err, client.ErrHeightNotAvailable)
+	assert.Equal(t, 1, attempts, "A future height should not be retried by default")
+
+	attempts = 0
+	cl.Config.Retry.RetryHeightNotAvailable = true
+	err = cl.Retry(context.Background(), op)
+	require.ErrorIs(t, err, client.ErrHeightNotAvailable)
+	assert.Equal(t, 3, attempts, "A future height should be retried when opted in")
+}
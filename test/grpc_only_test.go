@@ -0,0 +1,54 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/RiemaLabs/probe/client"
+	querier "github.com/RiemaLabs/probe/query"
+	abci "github.com/cometbft/cometbft/abci/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGRPCOnlyClientWithoutRPC(t *testing.T) {
+	cdc, err := client.MakeCodec(client.DefaultModuleBasics, client.DefaultCustomMsgTypeRegistry)
+	require.NoError(t, err, "Failed to make codec")
+
+	// A client of the grpc transport without rpc-addr, as NewChainClient leaves it
+	cl := &client.ChainClient{
+		Config:        &client.ChainClientConfig{ChainID: fakeChainID, Transport: client.TransportGRPC, Timeout: "1s", VerifyProofs: true},
+		Codec:         cdc,
+		CodecSchedule: []client.ScheduledCodec{{AppVersion: 2, Codec: cdc}},
+	}
+	query := &querier.Query{Client: cl, Options: &querier.QueryOptions{}}
+	ctx := context.Background()
+	hash := strings.Repeat("AB", 32)
+
+	calls := map[string]func() error{
+		"BlockRPC": func() error { _, err := querier.BlockRPC(query); return err },
+		"BlockResultsRPC": func() error {
+			_, err := querier.BlockResultsRPC(&querier.Query{Client: cl, Options: &querier.QueryOptions{Height: 5}})
+			return err
+		},
+		"StatusRPC":  func() error { _, err := querier.StatusRPC(query); return err },
+		"QueryABCI":  func() error { _, err := cl.QueryABCI(ctx, abci.RequestQuery{Path: "/store/bank/key"}); return err },
+		"QueryStore": func() error { _, err := cl.QueryStore(ctx, "bank", []byte("key"), 0); return err },
+		"CodecAt":    func() error { _, err := cl.CodecAt(ctx, 5, cdc); return err },
+		"TxsAtHeightRPCTolerant": func() error {
+			_, _, err := querier.TxsAtHeightRPCTolerant(query, 5, cdc)
+			return err
+		},
+		"TxByHashRPC with proof": func() error { _, err := querier.TxByHashRPC(query, hash, true); return err },
+	}
+
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			var err error
+			require.NotPanics(t, func() { err = call() })
+			assert.ErrorIs(t, err, errors.ErrUnsupported)
+		})
+	}
+}
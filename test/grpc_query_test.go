@@ -0,0 +1,233 @@
+package test
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	sdkmath "cosmossdk.io/math"
+	"github.com/RiemaLabs/probe/client"
+	querier "github.com/RiemaLabs/probe/query"
+	abci "github.com/cometbft/cometbft/abci/types"
+	"github.com/cometbft/cometbft/p2p"
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	rpctypes "github.com/cometbft/cometbft/rpc/jsonrpc/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	legacyerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	grpctypes "github.com/cosmos/cosmos-sdk/types/grpc"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	gogoproto "github.com/cosmos/gogoproto/proto"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeABCINode answers the bank balance gRPC query over abci_query, with a balance equal to the
+// queried height. Unknown accounts are answered with a key not found error.
+type fakeABCINode struct {
+	mtx    sync.Mutex
+	latest int64
+	query  abci.RequestQuery
+}
+
+func (n *fakeABCINode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/websocket" {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}
+
+	var req rpctypes.RPCRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var params map[string]any
+	_ = json.Unmarshal(req.Params, &params)
+
+	var result any
+	switch req.Method {
+	case "status":
+		n.mtx.Lock()
+		result = &coretypes.ResultStatus{NodeInfo: p2p.DefaultNodeInfo{Network: fakeChainID}, SyncInfo: coretypes.SyncInfo{LatestBlockHeight: n.latest}}
+		n.mtx.Unlock()
+	case "abci_query":
+		data, _ := hex.DecodeString(params["data"].(string))
+		query := abci.RequestQuery{Path: params["path"].(string), Data: data, Height: int64(intParam(params, "height"))}
+		query.Prove, _ = params["prove"].(bool)
+		n.mtx.Lock()
+		n.query = query
+		height := query.Height
+		if height == 0 {
+			height = n.latest
+		}
+		n.mtx.Unlock()
+
+		var balanceReq banktypes.QueryBalanceRequest
+		switch {
+		case query.Path != "/cosmos.bank.v1beta1.Query/Balance" || gogoproto.Unmarshal(data, &balanceReq) != nil:
+			result = &coretypes.ResultABCIQuery{Response: abci.ResponseQuery{
+				Codespace: legacyerrors.ErrUnknownRequest.Codespace(),
+				Code:      legacyerrors.ErrUnknownRequest.ABCICode(),
+				Log:       "unknown query path",
+			}}
+		case balanceReq.Address != "bc1pholder":
+			result = &coretypes.ResultABCIQuery{Response: abci.ResponseQuery{
+				Codespace: legacyerrors.ErrKeyNotFound.Codespace(),
+				Code:      legacyerrors.ErrKeyNotFound.ABCICode(),
+				Log:       "account " + balanceReq.Address + " not found",
+				Height:    height,
+			}}
+		default:
+			coin := sdk.NewCoin(balanceReq.Denom, sdkmath.NewInt(height))
+			value, _ := gogoproto.Marshal(&banktypes.QueryBalanceResponse{Balance: &coin})
+			result = &coretypes.ResultABCIQuery{Response: abci.ResponseQuery{Value: value, Height: height}}
+		}
+	default:
+		http.Error(w, "unexpected method "+req.Method, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rpctypes.NewRPCSuccessResponse(req.ID, result))
+}
+
+func (n *fakeABCINode) lastQuery() abci.RequestQuery {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return n.query
+}
+
+func (n *fakeABCINode) setLatest(height int64) {
+	n.mtx.Lock()
+	n.latest = height
+	n.mtx.Unlock()
+}
+
+func newABCIClient(t *testing.T) (*fakeABCINode, *client.ChainClient) {
+	node := &fakeABCINode{latest: 100}
+	server := httptest.NewServer(node)
+	t.Cleanup(server.Close)
+
+	cl, err := client.NewChainClient(&client.ChainClientConfig{
+		ChainID:               fakeChainID,
+		RPCAddr:               server.URL,
+		Timeout:               "10s",
+		OutputFormat:          "json",
+		Modules:               client.DefaultModuleBasics,
+		CustomMsgTypeRegistry: client.DefaultCustomMsgTypeRegistry,
+	})
+	require.NoError(t, err, "Failed to create chain client")
+	return node, cl
+}
+
+func TestInvokeOverABCI(t *testing.T) {
+	node, cl := newABCIClient(t)
+	bank := banktypes.NewQueryClient(cl)
+	req := &banktypes.QueryBalanceRequest{Address: "bc1pholder", Denom: "ubtc"}
+
+	res, err := bank.Balance(context.Background(), req)
+	require.NoError(t, err, "Failed to query balance")
+	assert.Equal(t, "100ubtc", res.Balance.String(), "A query without height should run at the latest height")
+	assert.Equal(t, "/cosmos.bank.v1beta1.Query/Balance", node.lastQuery().Path)
+
+	var header metadata.MD
+	res, err = bank.Balance(client.SetHeightOnContext(context.Background(), 7), req, grpc.Header(&header))
+	require.NoError(t, err, "Failed to query balance at height")
+	assert.Equal(t, "7ubtc", res.Balance.String())
+	assert.Equal(t, int64(7), node.lastQuery().Height)
+	assert.Equal(t, []string{"7"}, header.Get(grpctypes.GRPCBlockHeightHeader), "The height of the answer should be returned in the header")
+
+	_, err = bank.Balance(context.Background(), &banktypes.QueryBalanceRequest{Address: "bc1pnobody", Denom: "ubtc"})
+	assert.ErrorIs(t, err, client.ErrNotFound)
+	assert.Equal(t, codes.NotFound, status.Code(err), "The ABCI code should be kept as a gRPC status")
+
+	assert.Error(t, cl.Invoke(context.Background(), "/cosmos.bank.v1beta1.Query/Balance", (*banktypes.QueryBalanceRequest)(nil), &banktypes.QueryBalanceResponse{}),
+		"A nil request should be rejected")
+
+	_, err = cl.NewStream(context.Background(), &grpc.StreamDesc{}, "/cosmos.bank.v1beta1.Query/Balance")
+	assert.Error(t, err)
+}
+
+func TestRunGRPCQuery(t *testing.T) {
+	node, cl := newABCIClient(t)
+	req := &banktypes.QueryBalanceRequest{Address: "bc1pholder", Denom: "ubtc"}
+
+	md := metadata.Pairs(grpctypes.GRPCBlockHeightHeader, "12", "x-cosmos-query-prove", "true")
+	res, outMd, err := cl.RunGRPCQuery(context.Background(), "/cosmos.bank.v1beta1.Query/Balance", req, md)
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), res.Height)
+	assert.Equal(t, []string{strconv.Itoa(12)}, outMd.Get(grpctypes.GRPCBlockHeightHeader))
+
+	query := node.lastQuery()
+	assert.True(t, query.Prove, "The proof requested in the metadata should be forwarded")
+	var sent banktypes.QueryBalanceRequest
+	require.NoError(t, gogoproto.Unmarshal(query.Data, &sent))
+	assert.Equal(t, req.Address, sent.Address)
+
+	_, _, err = cl.RunGRPCQuery(context.Background(), "/cosmos.bank.v1beta1.Query/Balance", req,
+		metadata.Pairs(grpctypes.GRPCBlockHeightHeader, "-1"))
+	assert.Error(t, err, "A negative height should be rejected")
+
+	_, _, err = cl.RunGRPCQuery(context.Background(), "/cosmos.bank.v1beta1.Query/Balance", "not a message", nil)
+	assert.Error(t, err)
+}
+
+func TestQueryABCI(t *testing.T) {
+	_, cl := newABCIClient(t)
+
+	_, err := cl.QueryABCI(context.Background(), abci.RequestQuery{Path: "/unknown"})
+	var abciErr *client.ABCIError
+	require.ErrorAs(t, err, &abciErr, "A failed query should return its ABCI code")
+	assert.Equal(t, legacyerrors.ErrUnknownRequest.ABCICode(), abciErr.Code)
+	assert.Equal(t, "unknown query path", abciErr.Log)
+	assert.ErrorIs(t, err, client.ErrABCICode)
+}
+
+func TestSnapshotPinsQueries(t *testing.T) {
+	node, cl := newABCIClient(t)
+	ctx := context.Background()
+	query := &querier.Query{Client: cl, Options: &querier.QueryOptions{}}
+
+	snapshot, err := query.Snapshot(ctx)
+	require.NoError(t, err, "Failed to take snapshot")
+	assert.Equal(t, int64(100), snapshot.Options.Height)
+	assert.Zero(t, query.Options.Height, "The snapshot should not change the original query")
+
+	node.setLatest(120)
+	bank := banktypes.NewQueryClient(cl)
+	req := &banktypes.QueryBalanceRequest{Address: "bc1pholder", Denom: "ubtc"}
+
+	res, err := bank.Balance(snapshot.HeightContext(ctx), req)
+	require.NoError(t, err)
+	assert.Equal(t, "100ubtc", res.Balance.String(), "Queries of a snapshot should read the state of its height")
+
+	res, err = bank.Balance(query.HeightContext(ctx), req)
+	require.NoError(t, err)
+	assert.Equal(t, "120ubtc", res.Balance.String(), "Queries without height should read the latest state")
+
+	pinned := &querier.Query{Client: cl, Options: &querier.QueryOptions{Height: 42}}
+	snapshot, err = pinned.Snapshot(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), snapshot.Options.Height, "A query with a height should keep it")
+	assert.Equal(t, int64(42), snapshot.WasmQuery("bc1pcontract").Options.Height)
+
+	assert.Equal(t, ctx, (&querier.Query{Client: cl}).HeightContext(ctx), "A query without options should not be pinned")
+}
@@ -0,0 +1,138 @@
+package test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	probeCodecTypes "github.com/RiemaLabs/probe/client/codec/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"github.com/cosmos/gogoproto/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Two interfaces every message implements, to register a same type URL for both
+type (
+	firstTestInterface  interface{ proto.Message }
+	secondTestInterface interface{ proto.Message }
+)
+
+const conflictingTypeURL = "/probe.test.Conflicting"
+
+func newTestRegistry(t *testing.T, policy probeCodecTypes.ConflictPolicy) *probeCodecTypes.ProbeInterfaceRegistry {
+	_, registry := probeCodecTypes.NewInterfaceRegistry()
+	registry.SetConflictPolicy(policy)
+	require.NoError(t, registry.TryRegisterInterface("probe.test.First", (*firstTestInterface)(nil)))
+	require.NoError(t, registry.TryRegisterInterface("probe.test.Second", (*secondTestInterface)(nil)))
+	return registry
+}
+
+func requireResolves(t *testing.T, registry *probeCodecTypes.ProbeInterfaceRegistry, typeURL string, want proto.Message) {
+	msg, err := registry.Resolve(typeURL)
+	require.NoError(t, err)
+	assert.IsType(t, want, msg)
+}
+
+func TestInterfaceRegistryConflictError(t *testing.T) {
+	registry := newTestRegistry(t, probeCodecTypes.ConflictError)
+
+	require.NoError(t, registry.RegisterCustomTypeURL((*firstTestInterface)(nil), conflictingTypeURL, &banktypes.MsgSend{}))
+	require.NoError(t, registry.RegisterCustomTypeURL((*firstTestInterface)(nil), conflictingTypeURL, &banktypes.MsgSend{}),
+		"Registering the same type again is not a conflict")
+
+	err := registry.RegisterCustomTypeURL((*firstTestInterface)(nil), conflictingTypeURL, &stakingtypes.MsgDelegate{})
+	assert.Error(t, err, "Another type under the same type URL should be a conflict")
+
+	err = registry.RegisterCustomTypeURL((*secondTestInterface)(nil), conflictingTypeURL, &stakingtypes.MsgDelegate{})
+	assert.Error(t, err, "Another type under the same type URL of another interface should be a conflict")
+
+	requireResolves(t, registry, conflictingTypeURL, &banktypes.MsgSend{})
+	assert.Empty(t, registry.ListImplementations("probe.test.Second"))
+}
+
+func TestInterfaceRegistryConflictKeepFirst(t *testing.T) {
+	registry := newTestRegistry(t, probeCodecTypes.ConflictKeepFirst)
+
+	require.NoError(t, registry.RegisterCustomTypeURL((*firstTestInterface)(nil), conflictingTypeURL, &banktypes.MsgSend{}))
+	require.NoError(t, registry.RegisterCustomTypeURL((*secondTestInterface)(nil), conflictingTypeURL, &stakingtypes.MsgDelegate{}))
+
+	requireResolves(t, registry, conflictingTypeURL, &banktypes.MsgSend{})
+	assert.Equal(t, []probeCodecTypes.Conflict{{
+		TypeURL:   conflictingTypeURL,
+		Interface: "probe.test.Second",
+		Existing:  "*types.MsgSend",
+		New:       "*types.MsgDelegate",
+		Winner:    "*types.MsgSend",
+	}}, registry.Diagnostics())
+}
+
+func TestInterfaceRegistryConflictOverrideWithCustom(t *testing.T) {
+	registry := newTestRegistry(t, probeCodecTypes.ConflictOverrideWithCustom)
+
+	require.NoError(t, registry.TryRegisterImplementations((*firstTestInterface)(nil), &banktypes.MsgSend{}))
+	typeURL := "/" + proto.MessageName(&banktypes.MsgSend{})
+
+	// Only custom registrations override
+	require.NoError(t, registry.TryRegisterImplementations((*secondTestInterface)(nil), &banktypes.MsgSend{}))
+	requireResolves(t, registry, typeURL, &banktypes.MsgSend{})
+
+	require.NoError(t, registry.RegisterCustomTypeURL((*secondTestInterface)(nil), typeURL, &stakingtypes.MsgDelegate{}))
+	requireResolves(t, registry, typeURL, &stakingtypes.MsgDelegate{})
+	assert.NotContains(t, registry.ListImplementations("probe.test.First"), typeURL,
+		"The replaced type should no longer be registered under the type URL")
+	assert.Contains(t, registry.ListImplementations("probe.test.Second"), typeURL)
+
+	diagnostics := registry.Diagnostics()
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, "*types.MsgDelegate", diagnostics[0].Winner)
+}
+
+func TestInterfaceRegistryFreeze(t *testing.T) {
+	registry := newTestRegistry(t, probeCodecTypes.ConflictError)
+	require.NoError(t, registry.TryRegisterImplementations((*firstTestInterface)(nil), &banktypes.MsgSend{}))
+
+	registry.Freeze()
+	assert.True(t, registry.IsFrozen())
+
+	assert.Error(t, registry.TryRegisterImplementations((*firstTestInterface)(nil), &stakingtypes.MsgDelegate{}))
+	assert.Error(t, registry.RegisterCustomTypeURL((*firstTestInterface)(nil), conflictingTypeURL, &stakingtypes.MsgDelegate{}))
+
+	require.NoError(t, registry.Err())
+	registry.RegisterImplementations((*firstTestInterface)(nil), &stakingtypes.MsgDelegate{})
+	assert.Error(t, registry.Err(), "Registrations without error result should record their failure")
+
+	requireResolves(t, registry, "/"+proto.MessageName(&banktypes.MsgSend{}), &banktypes.MsgSend{})
+	assert.False(t, registry.TypeURLIsRegistered("/"+proto.MessageName(&stakingtypes.MsgDelegate{})))
+}
+
+func TestInterfaceRegistryConcurrentUse(t *testing.T) {
+	registry := newTestRegistry(t, probeCodecTypes.ConflictError)
+	require.NoError(t, registry.TryRegisterImplementations((*firstTestInterface)(nil), &banktypes.MsgSend{}))
+	sendTypeURL := "/" + proto.MessageName(&banktypes.MsgSend{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				typeURL := fmt.Sprintf("/probe.test.Msg%d_%d", i, j)
+				assert.NoError(t, registry.RegisterCustomTypeURL((*secondTestInterface)(nil), typeURL, &stakingtypes.MsgDelegate{}))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				msg, err := registry.Resolve(sendTypeURL)
+				assert.NoError(t, err)
+				assert.IsType(t, &banktypes.MsgSend{}, msg)
+				_ = registry.ListImplementations("probe.test.Second")
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, registry.ListImplementations("probe.test.Second"), 8*50)
+}
@@ -0,0 +1,211 @@
+package test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RiemaLabs/probe/client"
+	dbm "github.com/cometbft/cometbft-db"
+	"github.com/cometbft/cometbft/light"
+	"github.com/cometbft/cometbft/light/provider"
+	"github.com/cometbft/cometbft/light/provider/mock"
+	dbs "github.com/cometbft/cometbft/light/store/db"
+	"github.com/cometbft/cometbft/p2p"
+	cmtproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	cmtversion "github.com/cometbft/cometbft/proto/tendermint/version"
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	rpctypes "github.com/cometbft/cometbft/rpc/jsonrpc/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+	"github.com/cometbft/cometbft/version"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signedChain builds the blocks of a chain with a single validator, committed one after the other
+func signedChain(t *testing.T, heights int64) ([]*cmttypes.Block, map[int64]*cmttypes.SignedHeader, map[int64]*cmttypes.ValidatorSet) {
+	vals, privVals := cmttypes.RandValidatorSet(1, 10)
+	start := time.Now().Add(-time.Hour)
+
+	var blocks []*cmttypes.Block
+	headers := map[int64]*cmttypes.SignedHeader{}
+	valSets := map[int64]*cmttypes.ValidatorSet{}
+	lastCommit, lastBlockID := &cmttypes.Commit{}, cmttypes.BlockID{}
+	for height := int64(1); height <= heights; height++ {
+		block := cmttypes.MakeBlock(height, []cmttypes.Tx{[]byte{byte(height)}}, lastCommit, nil)
+		block.Version = cmtversion.Consensus{Block: version.BlockProtocol}
+		block.ChainID = fakeChainID
+		block.Time = start.Add(time.Duration(height) * time.Minute)
+		block.LastBlockID = lastBlockID
+		block.ValidatorsHash = vals.Hash()
+		block.NextValidatorsHash = vals.Hash()
+		block.ProposerAddress = vals.Proposer.Address
+
+		partsHash := sha256.Sum256(block.Hash())
+		blockID := cmttypes.BlockID{Hash: block.Hash(), PartSetHeader: cmttypes.PartSetHeader{Total: 1, Hash: partsHash[:]}}
+		voteSet := cmttypes.NewVoteSet(fakeChainID, height, 0, cmtproto.PrecommitType, vals)
+		extCommit, err := cmttypes.MakeExtCommit(blockID, height, 0, voteSet, privVals, block.Time.Add(time.Second), false)
+		require.NoError(t, err, "Failed to sign block")
+
+		blocks = append(blocks, block)
+		headers[height] = &cmttypes.SignedHeader{Header: &block.Header, Commit: extCommit.ToCommit()}
+		valSets[height] = vals
+		lastCommit, lastBlockID = extCommit.ToCommit(), blockID
+	}
+	return blocks, headers, valSets
+}
+
+func newLightClient(t *testing.T) (*client.ChainClient, []*cmttypes.Block) {
+	blocks, headers, vals := signedChain(t, 3)
+	primary := mock.New(fakeChainID, headers, vals)
+
+	lc, err := light.NewClient(context.Background(), fakeChainID, light.TrustOptions{
+		Period: client.DefaultTrustingPeriod,
+		Height: 1,
+		Hash:   blocks[0].Hash(),
+	}, primary, []provider.Provider{mock.New(fakeChainID, headers, vals)}, dbs.New(dbm.NewMemDB(), fakeChainID), light.SequentialVerification())
+	require.NoError(t, err, "Failed to create light client")
+
+	return &client.ChainClient{Config: &client.ChainClientConfig{ChainID: fakeChainID}, LightClient: lc}, blocks
+}
+
+func TestLightClientVerifyHeader(t *testing.T) {
+	cl, blocks := newLightClient(t)
+	ctx := context.Background()
+
+	require.NoError(t, cl.VerifyHeader(ctx, &blocks[2].Header), "A header of the chain should be verified")
+
+	forged := blocks[2].Header
+	forged.AppHash = make([]byte, 32)
+	assert.ErrorIs(t, cl.VerifyHeader(ctx, &forged), client.ErrLightClientVerification, "A forged header should be rejected")
+
+	assert.NoError(t, (&client.ChainClient{Config: cl.Config}).VerifyHeader(ctx, &forged), "Headers are trusted without light client")
+}
+
+func TestLightClientVerifyBlock(t *testing.T) {
+	cl, blocks := newLightClient(t)
+	ctx := context.Background()
+
+	require.NoError(t, cl.VerifyBlock(ctx, blocks[1]), "A block of the chain should be verified")
+
+	forged := &cmttypes.Block{Header: blocks[1].Header, Data: cmttypes.Data{Txs: []cmttypes.Tx{[]byte("forged")}}, LastCommit: blocks[1].LastCommit}
+	assert.ErrorIs(t, cl.VerifyBlock(ctx, forged), client.ErrLightClientVerification, "Txs not matching the data hash should be rejected")
+
+	forged.Header.DataHash = forged.Data.Hash()
+	assert.ErrorIs(t, cl.VerifyBlock(ctx, forged), client.ErrLightClientVerification, "A block with another header should be rejected")
+
+	assert.NoError(t, cl.VerifyBlock(ctx, nil))
+}
+
+// signedChainNode serves the status of a signed chain and the commits and validators the light
+// client reads, over the CometBFT RPC
+type signedChainNode struct {
+	headers map[int64]*cmttypes.SignedHeader
+	vals    map[int64]*cmttypes.ValidatorSet
+}
+
+func (n *signedChainNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/websocket" {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}
+
+	var req rpctypes.RPCRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var params map[string]any
+	_ = json.Unmarshal(req.Params, &params)
+
+	height := int64(intParam(params, "height"))
+	if height == 0 {
+		height = int64(len(n.headers))
+	}
+
+	var result any
+	switch req.Method {
+	case "status":
+		result = &coretypes.ResultStatus{
+			NodeInfo: p2p.DefaultNodeInfo{Network: fakeChainID},
+			SyncInfo: coretypes.SyncInfo{LatestBlockHeight: int64(len(n.headers))},
+		}
+	case "commit":
+		result = coretypes.NewResultCommit(n.headers[height].Header, n.headers[height].Commit, true)
+	case "validators":
+		vals := n.vals[height]
+		result = &coretypes.ResultValidators{BlockHeight: height, Validators: vals.Validators, Count: vals.Size(), Total: vals.Size()}
+	default:
+		http.Error(w, "unexpected method "+req.Method, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rpctypes.NewRPCSuccessResponse(req.ID, result))
+}
+
+func TestNewLightClientWitness(t *testing.T) {
+	lcc := &client.LightClientConfig{TrustedHeight: 1, TrustedHash: strings.Repeat("AB", 32), DBDir: t.TempDir()}
+
+	_, _, err := client.NewLightClient(context.Background(), fakeChainID, "http://127.0.0.1:1", nil, lcc)
+	require.Error(t, err, "A light client without witness should not silently check the primary against itself")
+	assert.Contains(t, err.Error(), "allow-no-witness")
+
+	ccc := &client.ChainClientConfig{ChainID: fakeChainID, RPCAddr: "http://localhost:26657", Timeout: "10s", LightClient: lcc}
+	assert.ErrorContains(t, ccc.Validate(), "light-client requires a witness")
+
+	ccc.RPCAddrs = []string{"http://localhost:26658"}
+	assert.NoError(t, ccc.Validate(), "The other rpc addresses are the default witnesses")
+
+	ccc.RPCAddrs = nil
+	lcc.AllowNoWitness = true
+	assert.NoError(t, ccc.Validate())
+}
+
+func TestChainClientStopClosesLightStore(t *testing.T) {
+	blocks, headers, vals := signedChain(t, 3)
+	server := httptest.NewServer(&signedChainNode{headers: headers, vals: vals})
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	cl, err := client.NewChainClient(&client.ChainClientConfig{
+		ChainID:               fakeChainID,
+		RPCAddr:               server.URL,
+		Timeout:               "10s",
+		OutputFormat:          "json",
+		Modules:               client.DefaultModuleBasics,
+		CustomMsgTypeRegistry: client.DefaultCustomMsgTypeRegistry,
+		LightClient: &client.LightClientConfig{
+			TrustedHeight:  1,
+			TrustedHash:    hex.EncodeToString(blocks[0].Hash()),
+			DBDir:          dir,
+			AllowNoWitness: true,
+		},
+	})
+	require.NoError(t, err, "Failed to create chain client")
+	require.NoError(t, cl.VerifyHeader(context.Background(), &blocks[2].Header))
+
+	_, err = dbm.NewGoLevelDB("probe-light", dir)
+	require.Error(t, err, "The light client store should be held while the client runs")
+
+	require.NoError(t, cl.Stop())
+	db, err := dbm.NewGoLevelDB("probe-light", dir)
+	require.NoError(t, err, "Stop should close the light client store")
+	require.NoError(t, db.Close())
+}
@@ -0,0 +1,141 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/RiemaLabs/probe/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	configs := map[string]string{
+		"config.yaml": `
+chain-id: bitway-1
+rpc-addr: http://localhost:26657
+timeout: 10s
+output-format: indent
+retry:
+  max-attempts: 5
+  initial-backoff: 100ms
+`,
+		"config.json": `{
+  "chain-id": "bitway-1",
+  "rpc-addr": "http://localhost:26657",
+  "timeout": "10s",
+  "output-format": "indent",
+  "retry": {"max-attempts": 5, "initial-backoff": "100ms"}
+}`,
+	}
+
+	for name, content := range configs {
+		t.Run(name, func(t *testing.T) {
+			ccc, err := client.LoadConfig(writeConfig(t, name, content))
+			require.NoError(t, err, "Failed to load config")
+
+			assert.Equal(t, "bitway-1", ccc.ChainID)
+			assert.Equal(t, "http://localhost:26657", ccc.RPCAddr)
+			assert.Equal(t, "10s", ccc.Timeout)
+			assert.Equal(t, "indent", ccc.OutputFormat)
+			require.NotNil(t, ccc.Retry)
+			assert.Equal(t, 5, ccc.Retry.MaxAttempts)
+			assert.Equal(t, "100ms", ccc.Retry.InitialBackoff)
+			assert.Equal(t, client.DefaultModuleBasics, ccc.Modules, "The modules should default when no preset is listed")
+		})
+	}
+}
+
+func TestLoadConfigUnknownKeys(t *testing.T) {
+	configs := map[string]string{
+		"config.yaml":      "rpc-addr: http://localhost:26657\ntimeout: 10s\nrpc-adr: http://localhost:26658\n",
+		"config.json":      `{"rpc-addr": "http://localhost:26657", "timeout": "10s", "rpc-adr": "http://localhost:26658"}`,
+		"nested.yaml":      "rpc-addr: http://localhost:26657\ntimeout: 10s\nretry:\n  max-atempts: 5\n",
+		"unsupported.toml": `rpc-addr = "http://localhost:26657"`,
+	}
+
+	for name, content := range configs {
+		t.Run(name, func(t *testing.T) {
+			_, err := client.LoadConfig(writeConfig(t, name, content))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLoadConfigEnvOverrides(t *testing.T) {
+	path := writeConfig(t, "config.yaml", "rpc-addr: http://localhost:26657\ntimeout: 10s\nretry:\n  max-attempts: 5\n")
+
+	t.Setenv("PROBE_RPC_ADDR", "http://node:26657")
+	t.Setenv("PROBE_RPC_ADDRS", "http://a:26657, http://b:26657")
+	t.Setenv("PROBE_RETRY_INITIAL_BACKOFF", "250ms")
+	t.Setenv("PROBE_RETRY_JITTER", "0.5")
+	t.Setenv("PROBE_RETRY_RETRY_HEIGHT_NOT_AVAILABLE", "true")
+
+	ccc, err := client.LoadConfig(path)
+	require.NoError(t, err, "Failed to load config")
+	assert.Equal(t, "http://node:26657", ccc.RPCAddr)
+	assert.Equal(t, []string{"http://a:26657", "http://b:26657"}, ccc.RPCAddrs)
+	assert.Equal(t, 5, ccc.Retry.MaxAttempts, "The values of the file without variable should be kept")
+	assert.Equal(t, "250ms", ccc.Retry.InitialBackoff)
+	assert.Equal(t, 0.5, ccc.Retry.Jitter)
+	assert.True(t, ccc.Retry.RetryHeightNotAvailable)
+
+	// A nested config missing from the file is created by its variables
+	path = writeConfig(t, "config.yaml", "rpc-addr: http://localhost:26657\ntimeout: 10s\n")
+	t.Setenv("PROBE_RETRY_MAX_ATTEMPTS", "7")
+	ccc, err = client.LoadConfig(path)
+	require.NoError(t, err, "Failed to load config")
+	require.NotNil(t, ccc.Retry)
+	assert.Equal(t, 7, ccc.Retry.MaxAttempts)
+
+	t.Setenv("PROBE_RETRY_MAX_ATTEMPTS", "many")
+	_, err = client.LoadConfig(path)
+	assert.ErrorContains(t, err, "PROBE_RETRY_MAX_ATTEMPTS")
+}
+
+func TestValidateConfigReportsAllErrors(t *testing.T) {
+	ccc := &client.ChainClientConfig{
+		ChainID:      "bitway 1",
+		RPCAddr:      "localhost:26657",
+		Transport:    "carrier-pigeon",
+		Timeout:      "-1s",
+		OutputFormat: "xml",
+		Presets:      []string{"unknown"},
+		Retry:        &client.RetryPolicy{MaxAttempts: -1, Jitter: 2},
+	}
+
+	err := ccc.Validate()
+	require.Error(t, err)
+	lines := strings.Split(err.Error(), "\n")
+	for _, want := range []string{
+		`chain-id "bitway 1" must be made of letters, digits, '.', '_' and '-'`,
+		`unknown transport "carrier-pigeon"`,
+		`invalid rpc address: "localhost:26657" must start with`,
+		"timeout must be positive, got -1s",
+		`unknown output-format "xml"`,
+		`unknown preset "unknown"`,
+		"retry.max-attempts must not be negative, got -1",
+		"retry.jitter must be between 0 and 1, got 2",
+	} {
+		assert.Condition(t, func() bool {
+			for _, line := range lines {
+				if strings.HasPrefix(line, want) {
+					return true
+				}
+			}
+			return false
+		}, "Missing error %q in:\n%s", want, err)
+	}
+	assert.Len(t, lines, 8, "Each problem should be reported once")
+
+	ccc = &client.ChainClientConfig{RPCAddr: "http://localhost:26657", Timeout: "10s"}
+	assert.NoError(t, ccc.Validate())
+}
@@ -0,0 +1,76 @@
+package test
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/RiemaLabs/probe/client"
+	probeCodecTypes "github.com/RiemaLabs/probe/client/codec/types"
+	"github.com/cosmos/gogoproto/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModulePresetCombinations(t *testing.T) {
+	names := make([]string, 0, len(client.ModulePresets))
+	for name := range client.ModulePresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// The custom messages are registered along the presets by the configs
+	msgTypes := map[string]proto.Message{}
+	for typeURL, msg := range client.DefaultCustomMsgTypeRegistry {
+		msgTypes[typeURL] = msg
+	}
+
+	for mask := 1; mask < 1<<len(names); mask++ {
+		var presets []string
+		for i, name := range names {
+			if mask&(1<<i) != 0 {
+				presets = append(presets, name)
+			}
+		}
+
+		t.Run(strings.Join(presets, "+"), func(t *testing.T) {
+			modules, customTypes, err := client.ResolvePresets(presets...)
+			require.NoError(t, err)
+
+			seen := map[string]bool{}
+			for _, m := range modules {
+				assert.False(t, seen[m.Name()], "Module %s should be listed once", m.Name())
+				seen[m.Name()] = true
+			}
+
+			customTypes[client.InterfaceMsg] = msgTypes
+			cdc, err := client.MakeCodecWithPolicy(modules, customTypes, probeCodecTypes.ConflictError)
+			require.NoError(t, err, "The presets should build a codec without conflicts")
+			assert.Empty(t, cdc.ProbeInterfaceRegistry.Diagnostics())
+		})
+	}
+}
+
+func TestResolvePresets(t *testing.T) {
+	full, _, err := client.ResolvePresets("cosmos-sdk-full")
+	require.NoError(t, err)
+	combined, _, err := client.ResolvePresets("cosmos-sdk", "cosmos-sdk-full")
+	require.NoError(t, err)
+	assert.Len(t, combined, len(full), "The modules shared by presets should be merged")
+
+	modules, customTypes, err := client.ResolvePresets("ibc", "wasm")
+	require.NoError(t, err)
+	assert.Empty(t, customTypes[client.InterfacePubKey])
+	cdc, err := client.MakeCodecWithPolicy(modules, customTypes, probeCodecTypes.ConflictError)
+	require.NoError(t, err)
+	for _, typeURL := range []string{"/ibc.applications.transfer.v1.MsgTransfer", "/cosmwasm.wasm.v1.MsgExecuteContract"} {
+		assert.True(t, cdc.ProbeInterfaceRegistry.TypeURLIsRegistered(typeURL), "%s should be registered", typeURL)
+	}
+
+	_, customTypes, err = client.ResolvePresets("thunderbolt")
+	require.NoError(t, err)
+	assert.NotEmpty(t, customTypes[client.InterfacePubKey], "The thunderbolt preset should bring its public keys")
+
+	_, _, err = client.ResolvePresets("cosmos-sdk", "osmosis")
+	assert.ErrorContains(t, err, `unknown preset "osmosis"`)
+}
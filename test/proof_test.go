@@ -0,0 +1,242 @@
+package test
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"cosmossdk.io/log"
+	"cosmossdk.io/store/metrics"
+	"cosmossdk.io/store/rootmulti"
+	storetypes "cosmossdk.io/store/types"
+	wasmtypes "github.com/CosmWasm/wasmd/x/wasm/types"
+	"github.com/RiemaLabs/probe/client"
+	querier "github.com/RiemaLabs/probe/query"
+	"github.com/RiemaLabs/probe/query/bank"
+	"github.com/RiemaLabs/probe/query/staking"
+	abci "github.com/cometbft/cometbft/abci/types"
+	"github.com/cometbft/cometbft/p2p"
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	rpctypes "github.com/cometbft/cometbft/rpc/jsonrpc/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+	dbm "github.com/cosmos/cosmos-db"
+	"github.com/cosmos/cosmos-sdk/types/bech32"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	gogoproto "github.com/gogo/protobuf/proto"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStoreNode serves ABCI store queries with merkle proofs from a real multistore, committed
+// once at height 1. The header of height 2 carries its app hash.
+type fakeStoreNode struct {
+	store   *rootmulti.Store
+	appHash []byte
+	// tamper replaces the values returned by the store queries, the proofs are kept
+	tamper []byte
+}
+
+func newFakeStoreNode(t *testing.T, storeName string, state map[string][]byte) *fakeStoreNode {
+	store := rootmulti.NewStore(dbm.NewMemDB(), log.NewNopLogger(), metrics.NewNoOpMetrics())
+	key := storetypes.NewKVStoreKey(storeName)
+	store.MountStoreWithDB(key, storetypes.StoreTypeIAVL, nil)
+	require.NoError(t, store.LoadLatestVersion())
+
+	kv := store.GetCommitKVStore(key)
+	for k, v := range state {
+		kv.Set([]byte(k), v)
+	}
+	commit := store.Commit()
+	require.Equal(t, int64(1), commit.Version)
+
+	return &fakeStoreNode{store: store, appHash: commit.Hash}
+}
+
+func (n *fakeStoreNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/websocket" {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}
+
+	var req rpctypes.RPCRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var params map[string]any
+	_ = json.Unmarshal(req.Params, &params)
+
+	var result any
+	switch req.Method {
+	case "status":
+		result = &coretypes.ResultStatus{
+			NodeInfo: p2p.DefaultNodeInfo{Network: fakeChainID},
+			SyncInfo: coretypes.SyncInfo{LatestBlockHeight: 2},
+		}
+	case "header":
+		height := int64(intParam(params, "height"))
+		header := &cmttypes.Header{ChainID: fakeChainID, Height: height, Time: time.Unix(1700000000, 0).UTC()}
+		if height == 2 {
+			header.AppHash = n.appHash
+		}
+		result = &coretypes.ResultHeader{Header: header}
+	case "abci_query":
+		data, _ := hex.DecodeString(params["data"].(string))
+		path, _ := params["path"].(string)
+		height, _ := strconv.ParseInt(params["height"].(string), 10, 64)
+		prove, _ := params["prove"].(bool)
+
+		// Smart queries are answered without proof, like the app does
+		if !strings.HasPrefix(path, "/store/") {
+			value, _ := gogoproto.Marshal(&querier.SmartContractStateResponse{Data: []byte(`{"ok":true}`)})
+			result = &coretypes.ResultABCIQuery{Response: abci.ResponseQuery{Value: value, Height: 2}}
+			break
+		}
+
+		res, err := n.store.Query(&storetypes.RequestQuery{
+			Path:   strings.TrimPrefix(path, "/store"),
+			Data:   data,
+			Height: height,
+			Prove:  prove,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if n.tamper != nil {
+			res.Value = n.tamper
+		}
+		result = &coretypes.ResultABCIQuery{Response: abci.ResponseQuery{
+			Key:      res.Key,
+			Value:    res.Value,
+			ProofOps: res.ProofOps,
+			Height:   res.Height,
+		}}
+	default:
+		http.Error(w, "unexpected method "+req.Method, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rpctypes.NewRPCSuccessResponse(req.ID, result))
+}
+
+func newVerifyingClient(t *testing.T, node http.Handler) *client.ChainClient {
+	server := httptest.NewServer(node)
+	t.Cleanup(server.Close)
+
+	cl, err := client.NewChainClient(&client.ChainClientConfig{
+		ChainID:               fakeChainID,
+		RPCAddr:               server.URL,
+		Timeout:               "10s",
+		OutputFormat:          "json",
+		VerifyProofs:          true,
+		Modules:               client.DefaultModuleBasics,
+		CustomMsgTypeRegistry: client.DefaultCustomMsgTypeRegistry,
+	})
+	require.NoError(t, err, "Failed to create chain client")
+	return cl
+}
+
+func TestQueryStoreVerifiesProofs(t *testing.T) {
+	node := newFakeStoreNode(t, "bank", map[string][]byte{"present": []byte("value")})
+	cl := newVerifyingClient(t, node)
+
+	value, err := cl.QueryStore(t.Context(), "bank", []byte("present"), 0)
+	require.NoError(t, err, "A value matching its proof should be accepted")
+	assert.Equal(t, []byte("value"), value)
+
+	value, err = cl.QueryStore(t.Context(), "bank", []byte("absent"), 0)
+	require.NoError(t, err, "A proof of absence should be accepted")
+	assert.Nil(t, value)
+
+	node.tamper = []byte("forged")
+	_, err = cl.QueryStore(t.Context(), "bank", []byte("present"), 0)
+	assert.ErrorIs(t, err, client.ErrProofVerification, "A value not matching its proof should be rejected")
+
+	_, err = cl.QueryStore(t.Context(), "bank", []byte("absent"), 0)
+	assert.ErrorIs(t, err, client.ErrProofVerification, "A value proven absent should be rejected")
+}
+
+func TestCw20BalanceVerified(t *testing.T) {
+	contract, err := bech32.ConvertAndEncode("bc", make([]byte, 32))
+	require.NoError(t, err)
+	_, contractAddr, err := bech32.DecodeAndConvert(contract)
+	require.NoError(t, err)
+
+	holder := "bc1pholder"
+	storeKey := append(wasmtypes.GetContractStorePrefix(contractAddr), querier.Cw20BalanceKey(holder)...)
+	node := newFakeStoreNode(t, wasmtypes.StoreKey, map[string][]byte{string(storeKey): []byte(`"1000"`)})
+	cl := newVerifyingClient(t, node)
+
+	query := querier.WasmQuery{Client: cl, Options: &querier.WasmQueryOptions{ContractAddress: contract}}
+	balance, err := query.QueryCw20Balance(holder)
+	require.NoError(t, err, "The verified balance should be read from the contract storage")
+	assert.Equal(t, big.NewInt(1000), balance)
+
+	balance, err = query.QueryCw20Balance("bc1pnobody")
+	require.NoError(t, err)
+	assert.Zero(t, balance.Sign(), "An address without balance entry holds nothing")
+
+	node.tamper = []byte(`"999999"`)
+	_, err = query.QueryCw20Balance(holder)
+	assert.ErrorIs(t, err, client.ErrProofVerification, "A tampered balance should be rejected")
+}
+
+func TestSmartQueryUnverified(t *testing.T) {
+	cl := newVerifyingClient(t, newFakeStoreNode(t, wasmtypes.StoreKey, nil))
+	contract, err := bech32.ConvertAndEncode("bc", make([]byte, 32))
+	require.NoError(t, err)
+
+	query := querier.WasmQuery{Client: cl, Options: &querier.WasmQueryOptions{ContractAddress: contract}}
+	res, err := query.QueryContractState([]byte(`{"config":{}}`))
+	require.NoError(t, err, "Smart queries should still run when proofs are verified")
+	assert.JSONEq(t, `{"ok":true}`, string(res))
+
+	query.Options.RequireVerified = true
+	_, err = query.QueryContractState([]byte(`{"config":{}}`))
+	assert.ErrorIs(t, err, client.ErrProofVerification, "RequireVerified should reject unprovable smart queries")
+}
+
+func TestStakingListsUnverified(t *testing.T) {
+	cl := newVerifyingClient(t, newFakeStoreNode(t, stakingtypes.StoreKey, nil))
+	query := &querier.Query{Client: cl, Options: &querier.QueryOptions{RequireVerified: true}}
+
+	_, err := staking.ValidatorsRPC(query, nil, nil)
+	assert.ErrorIs(t, err, client.ErrProofVerification, "RequireVerified should reject the unprovable validators page")
+
+	_, err = staking.DelegatorDelegationsRPC(query, "bc1pholder", nil)
+	assert.ErrorIs(t, err, client.ErrProofVerification, "RequireVerified should reject the unprovable delegations")
+}
+
+func TestBalanceVerifiedInvalidDenom(t *testing.T) {
+	// Absence is only proven next to a present key
+	cl := newVerifyingClient(t, newFakeStoreNode(t, banktypes.StoreKey, map[string][]byte{"other": []byte("value")}))
+	holder, err := bech32.ConvertAndEncode("bc", make([]byte, 32))
+	require.NoError(t, err)
+	query := &querier.Query{Client: cl, Options: &querier.QueryOptions{}}
+
+	require.NotPanics(t, func() { _, err = bank.BalanceRPC(query, holder, "!") })
+	assert.Error(t, err, "An invalid denom should be rejected")
+
+	balance, err := bank.BalanceRPC(query, holder, "ubtc")
+	require.NoError(t, err)
+	assert.Equal(t, "0ubtc", balance.String(), "An address without balance entry holds nothing")
+}
@@ -0,0 +1,160 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	reflectionv1 "cosmossdk.io/api/cosmos/reflection/v1"
+	"github.com/RiemaLabs/probe/client"
+	probeCodecTypes "github.com/RiemaLabs/probe/client/codec/types"
+	abci "github.com/cometbft/cometbft/abci/types"
+	"github.com/cometbft/cometbft/p2p"
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	rpctypes "github.com/cometbft/cometbft/rpc/jsonrpc/types"
+	reflectionv2 "github.com/cosmos/cosmos-sdk/server/grpc/reflection/v2alpha1"
+	legacyerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	gogoproto "github.com/cosmos/gogoproto/proto"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protov2 "google.golang.org/protobuf/proto"
+)
+
+const (
+	reflectionFilesPath = "/cosmos.reflection.v1.ReflectionService/FileDescriptors"
+	reflectionCodecPath = "/cosmos.base.reflection.v2alpha1.ReflectionService/GetCodecDescriptor"
+	reflectionTxPath    = "/cosmos.base.reflection.v2alpha1.ReflectionService/GetTxDescriptor"
+)
+
+// fakeReflectionNode answers the ABCI queries of the reflection services it serves, the other
+// queries fail with an unknown request error
+type fakeReflectionNode struct {
+	services map[string][]byte
+}
+
+func newFakeReflectionNode(t *testing.T, v1, v2 bool) *fakeReflectionNode {
+	node := &fakeReflectionNode{services: map[string][]byte{}}
+	if v1 {
+		files, err := protov2.Marshal(&reflectionv1.FileDescriptorsResponse{Files: pingDescriptorSet().File})
+		require.NoError(t, err)
+		node.services[reflectionFilesPath] = files
+	}
+	if v2 {
+		codec, err := gogoproto.Marshal(&reflectionv2.GetCodecDescriptorResponse{Codec: &reflectionv2.CodecDescriptor{
+			Interfaces: []*reflectionv2.InterfaceDescriptor{{
+				Fullname: "cosmos.base.v1beta1.Msg",
+				InterfaceImplementers: []*reflectionv2.InterfaceImplementerDescriptor{
+					{Fullname: "cosmos.bank.v1beta1.MsgSend", TypeUrl: "/cosmos.bank.v1beta1.MsgSend"},
+					{Fullname: "probe.test.v1.MsgPing", TypeUrl: pingTypeURL},
+					{Fullname: "other.v1.MsgUnknown", TypeUrl: "/other.v1.MsgUnknown"},
+				},
+			}},
+		}})
+		require.NoError(t, err)
+		node.services[reflectionCodecPath] = codec
+
+		tx, err := gogoproto.Marshal(&reflectionv2.GetTxDescriptorResponse{Tx: &reflectionv2.TxDescriptor{
+			Msgs: []*reflectionv2.MsgDescriptor{{MsgTypeUrl: pingTypeURL}, {MsgTypeUrl: "/other.v1.MsgMissing"}},
+		}})
+		require.NoError(t, err)
+		node.services[reflectionTxPath] = tx
+	}
+	return node
+}
+
+func (n *fakeReflectionNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/websocket" {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}
+
+	var req rpctypes.RPCRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var params map[string]any
+	_ = json.Unmarshal(req.Params, &params)
+
+	var result any
+	switch req.Method {
+	case "status":
+		result = &coretypes.ResultStatus{NodeInfo: p2p.DefaultNodeInfo{Network: fakeChainID}}
+	case "abci_query":
+		path, _ := params["path"].(string)
+		value, ok := n.services[path]
+		if !ok {
+			result = &coretypes.ResultABCIQuery{Response: abci.ResponseQuery{
+				Codespace: legacyerrors.ErrUnknownRequest.Codespace(),
+				Code:      legacyerrors.ErrUnknownRequest.ABCICode(),
+				Log:       "unknown query path " + path,
+			}}
+			break
+		}
+		result = &coretypes.ResultABCIQuery{Response: abci.ResponseQuery{Value: value, Height: 42}}
+	default:
+		http.Error(w, "unexpected method "+req.Method, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rpctypes.NewRPCSuccessResponse(req.ID, result))
+}
+
+func newReflectionClient(t *testing.T, node *fakeReflectionNode, reflection bool) (*client.ChainClient, error) {
+	server := httptest.NewServer(node)
+	t.Cleanup(server.Close)
+
+	return client.NewChainClient(&client.ChainClientConfig{
+		ChainID:               fakeChainID,
+		RPCAddr:               server.URL,
+		Timeout:               "10s",
+		OutputFormat:          "json",
+		Reflection:            reflection,
+		Modules:               client.DefaultModuleBasics,
+		CustomMsgTypeRegistry: client.DefaultCustomMsgTypeRegistry,
+	})
+}
+
+func TestReflectionRegistersChainTypes(t *testing.T) {
+	cl, err := newReflectionClient(t, newFakeReflectionNode(t, true, true), true)
+	require.NoError(t, err, "Failed to create chain client")
+
+	report := cl.ReflectionReport
+	require.NotNil(t, report, "The reflection report should be kept on the client")
+	assert.Equal(t, []string{pingTypeURL}, report.Registered, "Registered types should be kept")
+	assert.Equal(t, []string{"/other.v1.MsgMissing", "/other.v1.MsgUnknown"}, report.Undecodable)
+
+	decoded, err := cl.Codec.TxConfig.TxDecoder()(pingTx(t, cl.Codec, pingDescriptorSet()))
+	require.NoError(t, err, "A tx with a reflected message should be decoded")
+	assert.IsType(t, &probeCodecTypes.DynamicMsg{}, decoded.GetMsgs()[0])
+}
+
+func TestReflectionCodecDescriptorOnly(t *testing.T) {
+	cl, err := newReflectionClient(t, newFakeReflectionNode(t, false, true), false)
+	require.NoError(t, err, "Failed to create chain client")
+	assert.Nil(t, cl.ReflectionReport)
+
+	report, err := cl.RegisterReflectedTypes(context.Background())
+	require.NoError(t, err, "The codec descriptor alone should be enough")
+	assert.Empty(t, report.Registered)
+	assert.Equal(t, []string{"/other.v1.MsgMissing", "/other.v1.MsgUnknown", pingTypeURL}, report.Undecodable,
+		"Types without descriptor should be reported")
+}
+
+func TestReflectionUnavailable(t *testing.T) {
+	_, err := newReflectionClient(t, newFakeReflectionNode(t, false, false), true)
+	assert.ErrorContains(t, err, "no reflection service answered")
+}
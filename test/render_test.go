@@ -0,0 +1,92 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	sdkmath "cosmossdk.io/math"
+	"github.com/RiemaLabs/probe/client"
+	querier "github.com/RiemaLabs/probe/query"
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	txTypes "github.com/cosmos/cosmos-sdk/types/tx"
+)
+
+func newRenderCodec(t *testing.T) client.Codec {
+	cdc, err := client.MakeCodec(client.DefaultModuleBasics, client.DefaultCustomMsgTypeRegistry)
+	require.NoError(t, err, "Failed to make codec")
+	return cdc
+}
+
+func TestRenderFormats(t *testing.T) {
+	cdc := newRenderCodec(t)
+	coin := sdk.NewCoin("ubtc", sdkmath.NewInt(1000))
+	balance := &banktypes.QueryBalanceResponse{Balance: &coin}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"", `{"balance":{"denom":"ubtc","amount":"1000"}}`},
+		{"json", `{"balance":{"denom":"ubtc","amount":"1000"}}`},
+		{"indent", "{\n  \"balance\": {\n    \"denom\": \"ubtc\",\n    \"amount\": \"1000\"\n  }\n}"},
+		{"yaml", "balance:\n  denom: ubtc\n  amount: \"1000\""},
+		{"text", "balance.amount  1000\nbalance.denom   ubtc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			bz, err := cdc.Render(balance, tt.format)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(bz))
+		})
+	}
+
+	_, err := cdc.Render(balance, "xml")
+	assert.ErrorContains(t, err, `unknown output format "xml"`)
+}
+
+func TestRenderProbeStructs(t *testing.T) {
+	cdc := newRenderCodec(t)
+
+	msg, err := codectypes.NewAnyWithValue(&banktypes.MsgSend{FromAddress: "bc1pfrom", ToAddress: "bc1pto"})
+	require.NoError(t, err)
+	events := []*querier.TxEvent{
+		{Tx: &txTypes.Tx{Body: &txTypes.TxBody{Messages: []*codectypes.Any{msg}}}},
+		{Err: errors.New("header unavailable")},
+	}
+
+	bz, err := cdc.Render(events, "json")
+	require.NoError(t, err)
+	assert.Contains(t, string(bz), `{"@type":"/cosmos.bank.v1beta1.MsgSend","from_address":"bc1pfrom","to_address":"bc1pto","amount":[]}`,
+		"The messages in Any values should be rendered with their fields")
+	assert.Contains(t, string(bz), `"TxResponse":null,"Err":null}`)
+	assert.Contains(t, string(bz), `{"Tx":null,"TxResponse":null,"Err":"header unavailable"}`, "Errors should be rendered as their message")
+
+	bz, err = cdc.Render([]byte(`{ "count": 1 }`), "json")
+	require.NoError(t, err)
+	assert.Equal(t, `{"count":1}`, string(bz), "JSON bytes should be rendered as is")
+
+	bz, err = cdc.Render([]byte{0xff, 0x00}, "json")
+	require.NoError(t, err)
+	assert.Equal(t, `"/wA="`, string(bz), "Other bytes should be base64 encoded")
+
+	bz, err = cdc.Render(&coretypes.ResultABCIQuery{}, "text")
+	require.NoError(t, err)
+	assert.Contains(t, string(bz), "response.code", "CometBFT results should be rendered as by the rpc")
+}
+
+func TestChainClientRenderTo(t *testing.T) {
+	cl := &client.ChainClient{Config: &client.ChainClientConfig{OutputFormat: "yaml"}, Codec: newRenderCodec(t)}
+	coin := sdk.NewCoin("ubtc", sdkmath.NewInt(1000))
+
+	var out bytes.Buffer
+	require.NoError(t, cl.RenderTo(&out, &banktypes.QueryBalanceResponse{Balance: &coin}))
+	assert.Equal(t, "balance:\n  denom: ubtc\n  amount: \"1000\"\n", out.String(), "The output format of the config should be used")
+}
@@ -0,0 +1,302 @@
+package test
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sdkmath "cosmossdk.io/math"
+	"github.com/RiemaLabs/probe/client"
+	querier "github.com/RiemaLabs/probe/query"
+	"github.com/cometbft/cometbft/p2p"
+	rpcclient "github.com/cometbft/cometbft/rpc/client"
+	cmttypes "github.com/cometbft/cometbft/types"
+	"github.com/cosmos/cosmos-sdk/client/grpc/cmtservice"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	grpctypes "github.com/cosmos/cosmos-sdk/types/grpc"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	gogoproto "github.com/cosmos/gogoproto/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	txTypes "github.com/cosmos/cosmos-sdk/types/tx"
+)
+
+// fakeAPI is the REST gateway of an app API server holding a single block. Like the real one, it
+// only returns decoded txs, their raw bytes are only found in the block.
+type fakeAPI struct {
+	t      *testing.T
+	cdc    client.Codec
+	height int64
+	txs    cmttypes.Txs
+
+	mtx      sync.Mutex
+	requests []*http.Request
+	fail     int
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mtx.Lock()
+	f.requests = append(f.requests, r)
+	fail := f.fail
+	f.mtx.Unlock()
+
+	if fail != 0 {
+		http.Error(w, "gateway failure", fail)
+		return
+	}
+
+	var res gogoproto.Message
+	switch path := r.URL.Path; {
+	case path == "/cosmos/base/tendermint/v1beta1/node_info":
+		nodeInfo := p2p.DefaultNodeInfo{Network: fakeChainID}
+		res = &cmtservice.GetNodeInfoResponse{DefaultNodeInfo: nodeInfo.ToProto()}
+	case path == "/cosmos/base/tendermint/v1beta1/syncing":
+		res = &cmtservice.GetSyncingResponse{}
+	case path == "/cosmos/base/tendermint/v1beta1/blocks/latest", path == fmt.Sprintf("/cosmos/base/tendermint/v1beta1/blocks/%d", f.height):
+		block := cmttypes.MakeBlock(f.height, f.txs, &cmttypes.Commit{}, nil)
+		block.ChainID = fakeChainID
+		block.Time = time.Unix(1700000000, 0).UTC()
+		block.ProposerAddress = make([]byte, 20)
+		blockProto, err := block.ToProto()
+		require.NoError(f.t, err)
+		blockID := cmttypes.BlockID{Hash: block.Hash()}
+		blockIDProto := blockID.ToProto()
+		res = &cmtservice.GetBlockByHeightResponse{BlockId: &blockIDProto, Block: blockProto}
+	case strings.HasPrefix(path, "/cosmos/tx/v1beta1/txs/"):
+		hash := strings.TrimPrefix(path, "/cosmos/tx/v1beta1/txs/")
+		for _, tx := range f.txs {
+			if strings.EqualFold(hash, hex.EncodeToString(tx.Hash())) {
+				decoded, txResponse := f.decode(tx)
+				res = &txTypes.GetTxResponse{Tx: decoded, TxResponse: txResponse}
+			}
+		}
+		if res == nil {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprintf(w, `{"code":5,"message":"tx not found: %s","details":[]}`, hash)
+			return
+		}
+	case path == "/cosmos/tx/v1beta1/txs":
+		// The txs of a height are not returned in block order
+		txs := &txTypes.GetTxsEventResponse{Total: uint64(len(f.txs))}
+		for i := len(f.txs) - 1; i >= 0; i-- {
+			decoded, txResponse := f.decode(f.txs[i])
+			txs.Txs = append(txs.Txs, decoded)
+			txs.TxResponses = append(txs.TxResponses, txResponse)
+		}
+		res = txs
+	case strings.HasPrefix(path, "/cosmos/bank/v1beta1/balances/"):
+		w.Header().Set("Grpc-Metadata-"+grpctypes.GRPCBlockHeightHeader, r.Header.Get(grpctypes.GRPCBlockHeightHeader))
+		res = &banktypes.QueryAllBalancesResponse{Balances: sdk.NewCoins(sdk.NewCoin("ubtc", sdkmath.NewInt(1000)))}
+	default:
+		http.Error(w, "unexpected route "+path, http.StatusNotImplemented)
+		return
+	}
+
+	body, err := f.cdc.Marshaler.MarshalJSON(res)
+	require.NoError(f.t, err)
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
+
+// decode returns a tx as the API server does, decoded and without its raw bytes nor its index.
+// The gas used tells the txs apart, it grows with the index of the tx.
+func (f *fakeAPI) decode(tx cmttypes.Tx) (*txTypes.Tx, *sdk.TxResponse) {
+	var decoded txTypes.Tx
+	require.NoError(f.t, f.cdc.Marshaler.Unmarshal(tx, &decoded))
+	return &decoded, &sdk.TxResponse{Height: f.height, TxHash: fmt.Sprintf("%X", tx.Hash()), GasUsed: 21000 + int64(f.txs.Index(tx))}
+}
+
+func (f *fakeAPI) blockRequests() int {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	blocks := 0
+	for _, r := range f.requests {
+		if strings.HasPrefix(r.URL.Path, "/cosmos/base/tendermint/v1beta1/blocks/") {
+			blocks++
+		}
+	}
+	return blocks
+}
+
+func (f *fakeAPI) lastRequest() *http.Request {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.requests[len(f.requests)-1]
+}
+
+func (f *fakeAPI) firstRequest() *http.Request {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.requests[0]
+}
+
+// nonCanonicalTx encodes a tx whose body fields are out of order. The chain accepts it, but
+// encoding the decoded tx again gives different bytes.
+func nonCanonicalTx(t *testing.T, cdc client.Codec) cmttypes.Tx {
+	memo := "non-canonical"
+	// timeout_height (field 3) before memo (field 2)
+	body := append([]byte{0x18, 0x05, 0x12, byte(len(memo))}, memo...)
+	authInfo, err := cdc.Marshaler.Marshal(&txTypes.AuthInfo{Fee: &txTypes.Fee{GasLimit: 200000}})
+	require.NoError(t, err)
+
+	raw, err := cdc.Marshaler.Marshal(&txTypes.TxRaw{BodyBytes: body, AuthInfoBytes: authInfo, Signatures: [][]byte{{1, 2, 3}}})
+	require.NoError(t, err)
+	return raw
+}
+
+func newFakeAPI(t *testing.T) (*fakeAPI, *client.RESTClient) {
+	cdc, err := client.MakeCodec(client.DefaultModuleBasics, client.DefaultCustomMsgTypeRegistry)
+	require.NoError(t, err, "Failed to make codec")
+
+	builder := cdc.TxConfig.NewTxBuilder()
+	builder.SetMemo("canonical")
+	canonical, err := cdc.TxConfig.TxEncoder()(builder.GetTx())
+	require.NoError(t, err)
+
+	api := &fakeAPI{t: t, cdc: cdc, height: 42, txs: []cmttypes.Tx{nonCanonicalTx(t, cdc), canonical}}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	rc, err := client.NewRESTClient(server.URL, 5*time.Second, false, cdc)
+	require.NoError(t, err, "Failed to create rest client")
+	return api, rc
+}
+
+func TestRESTTxKeepsRawBytes(t *testing.T) {
+	api, rc := newFakeAPI(t)
+	ctx := context.Background()
+
+	res, err := rc.Tx(ctx, api.txs[0].Hash(), false)
+	require.NoError(t, err)
+	assert.Equal(t, api.txs[0], res.Tx, "The raw bytes of a non canonical tx should be read from its block")
+	assert.Equal(t, int64(21000), res.TxResult.GasUsed)
+	assert.Equal(t, uint32(0), res.Index)
+
+	res, err = rc.Tx(ctx, api.txs[1].Hash(), false)
+	require.NoError(t, err)
+	assert.Equal(t, api.txs[1], res.Tx)
+	assert.Equal(t, int64(21001), res.TxResult.GasUsed)
+	assert.Equal(t, uint32(1), res.Index, "The index of a canonically encoded tx should also be its position in the block")
+}
+
+func TestRESTTxSearchKeepsRawBytes(t *testing.T) {
+	api, rc := newFakeAPI(t)
+	page, perPage := 1, 100
+
+	res, err := rc.TxSearch(context.Background(), "tx.height=42", false, &page, &perPage, "asc")
+	require.NoError(t, err)
+	require.Len(t, res.Txs, 2)
+	assert.Equal(t, 2, res.TotalCount)
+	for _, tx := range res.Txs {
+		require.Less(t, int(tx.Index), len(api.txs))
+		assert.Equal(t, api.txs[tx.Index], tx.Tx)
+		assert.Equal(t, api.txs[tx.Index].Hash(), tx.Hash.Bytes())
+		assert.Equal(t, 21000+int64(tx.Index), tx.TxResult.GasUsed)
+	}
+	assert.Equal(t, uint32(1), res.Txs[0].Index, "The index should be read from the block, whatever the search order")
+	assert.Equal(t, 1, api.blockRequests(), "The block should be fetched once per height")
+
+	params := api.firstRequest().URL.Query()
+	assert.Equal(t, "tx.height=42", params.Get("query"))
+	assert.Equal(t, "100", params.Get("limit"))
+	assert.Equal(t, txTypes.OrderBy_ORDER_BY_ASC.String(), params.Get("order_by"))
+}
+
+func TestRESTBlockResults(t *testing.T) {
+	api, rc := newFakeAPI(t)
+	height := int64(42)
+
+	res, err := rc.BlockResults(context.Background(), &height)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), res.Height)
+	require.Len(t, res.TxsResults, 2)
+	for i, txResult := range res.TxsResults {
+		assert.Equal(t, 21000+int64(i), txResult.GasUsed, "The tx results should be in block order")
+	}
+	assert.Empty(t, res.FinalizeBlockEvents, "The api server does not expose the finalize block events")
+	assert.Empty(t, res.ValidatorUpdates, "The api server does not expose the validator updates")
+	assert.Equal(t, 1, api.blockRequests(), "The block should be fetched once")
+
+	res, err = rc.BlockResults(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), res.Height, "The latest height should be used without a height")
+}
+
+func TestRESTTxsFromBlock(t *testing.T) {
+	api, rc := newFakeAPI(t)
+	cl := &client.ChainClient{
+		Config:        &client.ChainClientConfig{ChainID: fakeChainID, Transport: client.TransportREST, Timeout: "5s"},
+		RPCClient:     rc,
+		Codec:         api.cdc,
+		CodecSchedule: []client.ScheduledCodec{{AppVersion: 2, Codec: api.cdc}},
+	}
+
+	res, err := querier.TxsFromBlockRPC(&querier.Query{Client: cl, Options: &querier.QueryOptions{}}, 42, api.cdc)
+	require.NoError(t, err, "The txs of a block should be built from the block results of the api server")
+	require.Len(t, res.TxResponses, 2)
+	for i, txResp := range res.TxResponses {
+		assert.Equal(t, fmt.Sprintf("%X", api.txs[i].Hash()), txResp.TxHash)
+		assert.Equal(t, 21000+int64(i), txResp.GasUsed)
+	}
+}
+
+func TestRESTStatusAndBlock(t *testing.T) {
+	api, rc := newFakeAPI(t)
+	ctx := context.Background()
+
+	status, err := rc.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, fakeChainID, status.NodeInfo.Network)
+	assert.Equal(t, api.height, status.SyncInfo.LatestBlockHeight)
+
+	block, err := rc.Block(ctx, &api.height)
+	require.NoError(t, err)
+	assert.Equal(t, cmttypes.Txs(api.txs), block.Block.Txs)
+}
+
+func TestRESTErrors(t *testing.T) {
+	api, rc := newFakeAPI(t)
+	ctx := context.Background()
+
+	_, err := rc.Tx(ctx, make([]byte, 32), false)
+	assert.ErrorIs(t, client.ClassifyError(err), client.ErrNotFound, "A gateway not found error should be classified")
+
+	_, err = rc.Tx(ctx, api.txs[0].Hash(), true)
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+
+	api.mtx.Lock()
+	api.fail = http.StatusServiceUnavailable
+	api.mtx.Unlock()
+	_, err = rc.Status(ctx)
+	assert.True(t, client.IsTransient(err), "A gateway 503 should be transient, got %v", err)
+}
+
+func TestRESTGRPCQuery(t *testing.T) {
+	api, rc := newFakeAPI(t)
+
+	req, err := api.cdc.Marshaler.Marshal(&banktypes.QueryAllBalancesRequest{Address: "bc1pholder"})
+	require.NoError(t, err)
+
+	res, err := rc.ABCIQueryWithOptions(context.Background(), "/cosmos.bank.v1beta1.Query/AllBalances", req, rpcclient.ABCIQueryOptions{Height: 7})
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), res.Response.Height)
+
+	var balances banktypes.QueryAllBalancesResponse
+	require.NoError(t, api.cdc.Marshaler.Unmarshal(res.Response.Value, &balances))
+	assert.Equal(t, "1000ubtc", balances.Balances.String())
+
+	last := api.lastRequest()
+	assert.Equal(t, "/cosmos/bank/v1beta1/balances/bc1pholder", last.URL.Path, "The route variables should be filled from the request")
+	assert.Equal(t, url.Values{}, last.URL.Query(), "Default request fields should not be sent")
+	assert.Equal(t, strconv.Itoa(7), last.Header.Get(grpctypes.GRPCBlockHeightHeader))
+}
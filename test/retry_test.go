@@ -0,0 +1,93 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/RiemaLabs/probe/client"
+	querier "github.com/RiemaLabs/probe/query"
+	grpctypes "github.com/cosmos/cosmos-sdk/types/grpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRetryTimeoutPerAttempt(t *testing.T) {
+	_, cl := newFakeChain(t, 0, false)
+	cl.Config.Timeout = "50ms"
+	cl.Config.Retry = &client.RetryPolicy{MaxAttempts: 3, InitialBackoff: "1ms"}
+
+	var deadlines []time.Time
+	err := cl.Retry(context.Background(), func(ctx context.Context) error {
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok, "Attempts should be bounded by the configured timeout")
+		deadlines = append(deadlines, deadline)
+		if len(deadlines) == 1 {
+			// A slow answer times out the first attempt
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	})
+
+	require.NoError(t, err, "An attempt that timed out should be retried")
+	require.Len(t, deadlines, 2)
+	assert.True(t, deadlines[1].After(deadlines[0]), "Every attempt should get its own deadline")
+}
+
+func TestRetryBoundedByCaller(t *testing.T) {
+	_, cl := newFakeChain(t, 0, false)
+	cl.Config.Retry = &client.RetryPolicy{MaxAttempts: 1000, InitialBackoff: "20ms", MaxBackoff: "20ms"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	attempts := 0
+	start := time.Now()
+	err := cl.Retry(ctx, func(ctx context.Context) error {
+		attempts++
+		return errors.New("dial tcp 127.0.0.1:26657: connect: connection refused")
+	})
+
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second, "The context of the caller should bound the retries")
+	assert.Greater(t, attempts, 1, "The transient failure should have been retried")
+}
+
+func TestGetQueryContext(t *testing.T) {
+	_, cl := newFakeChain(t, 0, false)
+
+	query := querier.Query{Client: cl, Options: &querier.QueryOptions{Height: 42}}
+	ctx, cancel := query.GetQueryContext()
+	defer cancel()
+	_, ok := ctx.Deadline()
+	assert.True(t, ok, "The context should be bounded by the configured timeout")
+	md, _ := metadata.FromOutgoingContext(ctx)
+	assert.Equal(t, []string{"42"}, md.Get(grpctypes.GRPCBlockHeightHeader))
+
+	wasmQuery := query.WasmQuery("bc1pcontract")
+	ctx, cancel = wasmQuery.GetQueryContext()
+	defer cancel()
+	_, ok = ctx.Deadline()
+	assert.True(t, ok, "The context should be bounded by the configured timeout")
+	md, _ = metadata.FromOutgoingContext(ctx)
+	assert.Equal(t, []string{"42"}, md.Get(grpctypes.GRPCBlockHeightHeader))
+}
+
+func TestRetryKeepsCallerDeadline(t *testing.T) {
+	_, cl := newFakeChain(t, 0, false)
+	cl.Config.Timeout = "1h"
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	want, _ := ctx.Deadline()
+
+	err := cl.Retry(ctx, func(ctx context.Context) error {
+		deadline, _ := ctx.Deadline()
+		assert.Equal(t, want, deadline, "The earlier deadline of the caller should win")
+		return nil
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,366 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RiemaLabs/probe/client"
+	"github.com/cometbft/cometbft/p2p"
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	rpctypes "github.com/cometbft/cometbft/rpc/jsonrpc/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNode is a CometBFT RPC node serving status, abci_info, block, broadcast_tx_sync and the
+// websocket subscription methods. It can be taken down, in which case it answers every request
+// with a 503, and its network, height and the errors of its calls can be changed.
+type fakeNode struct {
+	server *httptest.Server
+
+	mtx           sync.Mutex
+	network       string
+	height        int64
+	down          bool
+	abciErr       string
+	failBroadcast bool
+	calls         map[string]int
+	wsCalls       []string
+}
+
+func newFakeNode(t *testing.T, network string) *fakeNode {
+	node := &fakeNode{network: network, height: 100, calls: map[string]int{}}
+	node.server = httptest.NewServer(node)
+	t.Cleanup(node.server.Close)
+	return node
+}
+
+func (n *fakeNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.mtx.Lock()
+	down, network := n.down, n.network
+	n.mtx.Unlock()
+
+	if down {
+		http.Error(w, "node is down", http.StatusServiceUnavailable)
+		return
+	}
+
+	if r.URL.Path == "/websocket" {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var req rpctypes.RPCRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+
+			var params struct {
+				Query string `json:"query"`
+			}
+			_ = json.Unmarshal(req.Params, &params)
+
+			n.mtx.Lock()
+			n.wsCalls = append(n.wsCalls, strings.TrimSpace(req.Method+" "+params.Query))
+			n.mtx.Unlock()
+
+			if err := conn.WriteJSON(rpctypes.NewRPCSuccessResponse(req.ID, &coretypes.ResultSubscribe{})); err != nil {
+				return
+			}
+		}
+	}
+
+	var req rpctypes.RPCRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	n.mtx.Lock()
+	n.calls[req.Method]++
+	abciErr, failBroadcast, height := n.abciErr, n.failBroadcast, n.height
+	n.mtx.Unlock()
+
+	var params map[string]any
+	_ = json.Unmarshal(req.Params, &params)
+
+	var result any
+	switch req.Method {
+	case "status":
+		result = &coretypes.ResultStatus{
+			NodeInfo: p2p.DefaultNodeInfo{Network: network},
+			SyncInfo: coretypes.SyncInfo{LatestBlockHeight: height},
+		}
+	case "block":
+		if requested := int64(intParam(params, "height")); requested > height {
+			_ = json.NewEncoder(w).Encode(rpctypes.RPCInternalError(req.ID,
+				fmt.Errorf("height %d must be less than or equal to the current blockchain height %d", requested, height)))
+			return
+		}
+		result = &coretypes.ResultBlock{Block: &cmttypes.Block{Header: cmttypes.Header{ChainID: network, Height: height}}}
+	case "abci_info":
+		if abciErr != "" {
+			_ = json.NewEncoder(w).Encode(rpctypes.RPCInternalError(req.ID, errors.New(abciErr)))
+			return
+		}
+		result = &coretypes.ResultABCIInfo{}
+	case "broadcast_tx_sync":
+		if failBroadcast {
+			http.Error(w, "node is overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		result = &coretypes.ResultBroadcastTx{}
+	default:
+		http.Error(w, "unexpected method "+req.Method, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rpctypes.NewRPCSuccessResponse(req.ID, result))
+}
+
+func (n *fakeNode) setDown(down bool) {
+	n.mtx.Lock()
+	n.down = down
+	n.mtx.Unlock()
+}
+
+func (n *fakeNode) setNetwork(network string) {
+	n.mtx.Lock()
+	n.network = network
+	n.mtx.Unlock()
+}
+
+func (n *fakeNode) set(fn func(n *fakeNode)) {
+	n.mtx.Lock()
+	fn(n)
+	n.mtx.Unlock()
+}
+
+func (n *fakeNode) websocketCalls() []string {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return slices.Clone(n.wsCalls)
+}
+
+func (n *fakeNode) callCount(method string) int {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return n.calls[method]
+}
+
+func newTestPool(t *testing.T, interval time.Duration, nodes ...*fakeNode) *client.RPCPool {
+	addrs := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		addrs = append(addrs, node.server.URL)
+	}
+
+	pool, err := client.NewRPCPool(addrs, fakeChainID, 5*time.Second, false, interval)
+	require.NoError(t, err, "Failed to create rpc pool")
+	t.Cleanup(func() {
+		if pool.IsRunning() {
+			_ = pool.Stop()
+		}
+	})
+	return pool
+}
+
+func TestRPCPoolExcludesOtherChains(t *testing.T) {
+	other := newFakeNode(t, "other-chain")
+	good := newFakeNode(t, fakeChainID)
+	pool := newTestPool(t, 20*time.Millisecond, other, good)
+	require.NoError(t, pool.Start())
+
+	ctx := context.Background()
+	for i := 0; i < 4; i++ {
+		_, err := pool.ABCIInfo(ctx)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 4, good.callCount("abci_info"))
+
+	// The node of the other chain stays excluded when it is the only one left, even once it
+	// claims the right network
+	good.setDown(true)
+	other.setNetwork(fakeChainID)
+	time.Sleep(100 * time.Millisecond)
+
+	_, err := pool.ABCIInfo(ctx)
+	require.Error(t, err, "The down node should fail the call")
+	assert.Zero(t, other.callCount("abci_info"), "The node of another chain should never serve a call")
+}
+
+func TestRPCPoolOnlyOtherChains(t *testing.T) {
+	pool := newTestPool(t, time.Minute, newFakeNode(t, "other-chain"), newFakeNode(t, "another-chain"))
+
+	_, err := pool.ABCIInfo(context.Background())
+	assert.ErrorIs(t, err, client.ErrChainIDMismatch)
+}
+
+func TestRPCPoolRoundRobin(t *testing.T) {
+	nodes := []*fakeNode{newFakeNode(t, fakeChainID), newFakeNode(t, fakeChainID), newFakeNode(t, fakeChainID)}
+	pool := newTestPool(t, time.Minute, nodes...)
+
+	for i := 0; i < 9; i++ {
+		_, err := pool.ABCIInfo(context.Background())
+		require.NoError(t, err)
+	}
+
+	for _, node := range nodes {
+		assert.Equal(t, 3, node.callCount("abci_info"), "Calls should be spread evenly over the healthy nodes")
+	}
+}
+
+func TestRPCPoolFailover(t *testing.T) {
+	down, up := newFakeNode(t, fakeChainID), newFakeNode(t, fakeChainID)
+	pool := newTestPool(t, time.Minute, down, up)
+
+	// The node goes down after the health check, calls still succeed on the other one
+	down.setDown(true)
+	for i := 0; i < 4; i++ {
+		_, err := pool.ABCIInfo(context.Background())
+		require.NoError(t, err, "The call should fail over to the node that is up")
+	}
+	assert.Equal(t, 4, up.callCount("abci_info"))
+
+	// The failed node is re-checked in the background and leaves the rotation
+	require.Eventually(t, func() bool {
+		for _, status := range pool.Nodes() {
+			if status.Addr == down.server.URL {
+				return !status.Healthy
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRPCPoolNoFailoverOnDeterministicErrors(t *testing.T) {
+	nodes := []*fakeNode{newFakeNode(t, fakeChainID), newFakeNode(t, fakeChainID), newFakeNode(t, fakeChainID)}
+	for _, node := range nodes {
+		node.set(func(n *fakeNode) { n.abciErr = "invalid request: unknown field" })
+	}
+	pool := newTestPool(t, time.Minute, nodes...)
+
+	_, err := pool.ABCIInfo(context.Background())
+	require.Error(t, err)
+
+	calls := 0
+	for _, node := range nodes {
+		calls += node.callCount("abci_info")
+	}
+	assert.Equal(t, 1, calls, "An invalid request fails the same way on every node")
+}
+
+func TestRPCPoolFailoverOnHeights(t *testing.T) {
+	lagging, latest := newFakeNode(t, fakeChainID), newFakeNode(t, fakeChainID)
+	latest.set(func(n *fakeNode) { n.height = 103 })
+	pool := newTestPool(t, time.Minute, lagging, latest)
+
+	height := int64(103)
+	for i := 0; i < 4; i++ {
+		res, err := pool.Block(context.Background(), &height)
+		require.NoError(t, err, "A height the node has not reached should be fetched from another node")
+		assert.Equal(t, height, res.Block.Height)
+	}
+	assert.Equal(t, 2, lagging.callCount("block"), "The lagging node should have been tried in turn")
+
+	for _, status := range pool.Nodes() {
+		assert.True(t, status.Healthy, "A node lagging within the allowed lag should stay in the rotation")
+	}
+
+	lagging.set(func(n *fakeNode) { n.abciErr = "failed to load state at height 5" })
+	for i := 0; i < 2; i++ {
+		_, err := pool.ABCIInfo(context.Background())
+		require.NoError(t, err, "A height pruned by a node should be fetched from another node")
+	}
+}
+
+func TestRPCPoolHealthRecovery(t *testing.T) {
+	flaky, stable := newFakeNode(t, fakeChainID), newFakeNode(t, fakeChainID)
+	pool := newTestPool(t, 20*time.Millisecond, flaky, stable)
+	require.NoError(t, pool.Start())
+
+	healthy := func(node *fakeNode) bool {
+		for _, status := range pool.Nodes() {
+			if status.Addr == node.server.URL {
+				return status.Healthy
+			}
+		}
+		return false
+	}
+
+	flaky.setDown(true)
+	require.Eventually(t, func() bool { return !healthy(flaky) }, 5*time.Second, 10*time.Millisecond)
+
+	flaky.setDown(false)
+	require.Eventually(t, func() bool { return healthy(flaky) }, 5*time.Second, 10*time.Millisecond)
+
+	for i := 0; i < 4; i++ {
+		_, err := pool.ABCIInfo(context.Background())
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, flaky.callCount("abci_info"), "The recovered node should be back in the rotation")
+}
+
+func TestRPCPoolBroadcastsOnce(t *testing.T) {
+	nodes := []*fakeNode{newFakeNode(t, fakeChainID), newFakeNode(t, fakeChainID)}
+	for _, node := range nodes {
+		node.set(func(n *fakeNode) { n.failBroadcast = true })
+	}
+	pool := newTestPool(t, time.Minute, nodes...)
+
+	_, err := pool.BroadcastTxSync(context.Background(), []byte("tx"))
+	require.Error(t, err)
+
+	assert.Equal(t, 1, nodes[0].callCount("broadcast_tx_sync")+nodes[1].callCount("broadcast_tx_sync"),
+		"A broadcast should never be sent to a second node")
+}
+
+func TestRPCPoolSubscriptionsPerQuery(t *testing.T) {
+	nodes := []*fakeNode{newFakeNode(t, fakeChainID), newFakeNode(t, fakeChainID)}
+	pool := newTestPool(t, time.Minute, nodes...)
+	ctx := context.Background()
+
+	// Consecutive calls go to different nodes
+	_, err := pool.Subscribe(ctx, "probe", "tm.event='NewBlock'")
+	require.NoError(t, err)
+	_, err = pool.Subscribe(ctx, "probe", "tm.event='Tx'")
+	require.NoError(t, err)
+
+	require.NoError(t, pool.Unsubscribe(ctx, "probe", "tm.event='NewBlock'"))
+	require.NoError(t, pool.UnsubscribeAll(ctx, "probe"))
+
+	var blockNode, txNode *fakeNode
+	require.Eventually(t, func() bool {
+		for _, node := range nodes {
+			calls := node.websocketCalls()
+			if slices.Contains(calls, "subscribe tm.event='NewBlock'") {
+				blockNode = node
+			}
+			if slices.Contains(calls, "subscribe tm.event='Tx'") {
+				txNode = node
+			}
+		}
+		return blockNode != nil && txNode != nil &&
+			slices.Contains(blockNode.websocketCalls(), "unsubscribe tm.event='NewBlock'") &&
+			slices.Contains(txNode.websocketCalls(), "unsubscribe_all")
+	}, 5*time.Second, 10*time.Millisecond, "Unsubscribe calls should reach the nodes serving the subscriptions")
+
+	require.NotSame(t, blockNode, txNode)
+	assert.NotContains(t, blockNode.websocketCalls(), "unsubscribe_all")
+	assert.NotContains(t, txNode.websocketCalls(), "unsubscribe tm.event='NewBlock'")
+
+	assert.Error(t, pool.UnsubscribeAll(ctx, "probe"), "Every subscription should be forgotten")
+}
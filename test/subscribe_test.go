@@ -0,0 +1,277 @@
+package test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RiemaLabs/probe/client"
+	querier "github.com/RiemaLabs/probe/query"
+	abci "github.com/cometbft/cometbft/abci/types"
+	"github.com/cometbft/cometbft/p2p"
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	rpctypes "github.com/cometbft/cometbft/rpc/jsonrpc/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// errUnavailable makes fakeEventNode answer a call with a 503
+var errUnavailable = errors.New("unavailable")
+
+// fakeEventNode serves status, header and tx over HTTP and subscriptions over its websocket. The
+// events pushed with publish are sent to the last subscription.
+type fakeEventNode struct {
+	time time.Time
+
+	mtx           sync.Mutex
+	headerErrs    int
+	subscribes    int
+	cancellations int
+	conn          *websocket.Conn
+	subID         rpctypes.JSONRPCIntID
+	// noWebsocket refuses the websocket connections opened from then on
+	noWebsocket bool
+	// txErrs are returned by the tx calls in turn, the next ones return tx, or not found without it
+	txErrs []error
+	tx     *coretypes.ResultTx
+	txs    int
+}
+
+func (n *fakeEventNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/websocket" {
+		n.mtx.Lock()
+		refused := n.noWebsocket
+		n.mtx.Unlock()
+		if refused {
+			http.Error(w, "websocket disabled", http.StatusServiceUnavailable)
+			return
+		}
+		n.serveWebsocket(w, r)
+		return
+	}
+
+	var req rpctypes.RPCRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var params map[string]any
+	_ = json.Unmarshal(req.Params, &params)
+
+	var result any
+	switch req.Method {
+	case "status":
+		result = &coretypes.ResultStatus{NodeInfo: p2p.DefaultNodeInfo{Network: fakeChainID}}
+	case "header":
+		n.mtx.Lock()
+		fail := n.headerErrs > 0
+		if fail {
+			n.headerErrs--
+		}
+		n.mtx.Unlock()
+
+		if fail {
+			_ = json.NewEncoder(w).Encode(rpctypes.RPCInternalError(req.ID, errors.New("header store unavailable")))
+			return
+		}
+		height := int64(intParam(params, "height"))
+		result = &coretypes.ResultHeader{Header: &cmttypes.Header{ChainID: fakeChainID, Height: height, Time: n.time}}
+	case "tx":
+		n.mtx.Lock()
+		n.txs++
+		var err error
+		if len(n.txErrs) > 0 {
+			err, n.txErrs = n.txErrs[0], n.txErrs[1:]
+		} else if n.tx == nil {
+			hash, _ := base64.StdEncoding.DecodeString(params["hash"].(string))
+			err = fmt.Errorf("tx (%X) not found", hash)
+		}
+		tx := n.tx
+		n.mtx.Unlock()
+
+		switch {
+		case errors.Is(err, errUnavailable):
+			http.Error(w, "node is overloaded", http.StatusServiceUnavailable)
+			return
+		case err != nil:
+			_ = json.NewEncoder(w).Encode(rpctypes.RPCInternalError(req.ID, err))
+			return
+		}
+		result = tx
+	default:
+		http.Error(w, "unexpected method "+req.Method, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rpctypes.NewRPCSuccessResponse(req.ID, result))
+}
+
+func (n *fakeEventNode) serveWebsocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	for {
+		var req rpctypes.RPCRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+		if req.Method != "subscribe" {
+			continue
+		}
+
+		n.mtx.Lock()
+		n.subscribes++
+		n.conn, n.subID = conn, req.ID.(rpctypes.JSONRPCIntID)
+		err := conn.WriteJSON(rpctypes.NewRPCSuccessResponse(req.ID, &coretypes.ResultSubscribe{}))
+		// The first subscription is cancelled right away, with one error response per dropped event
+		if err == nil && n.subscribes == 1 {
+			for i := 0; i < n.cancellations && err == nil; i++ {
+				err = conn.WriteJSON(rpctypes.RPCServerError(req.ID, errors.New("subscription was cancelled (reason: client is not pulling messages fast enough)")))
+			}
+		}
+		n.mtx.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (n *fakeEventNode) publish(t *testing.T, event coretypes.ResultEvent) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	require.NotNil(t, n.conn, "No subscription to publish to")
+	require.NoError(t, n.conn.WriteJSON(rpctypes.NewRPCSuccessResponse(n.subID, &event)))
+}
+
+func (n *fakeEventNode) set(fn func(n *fakeEventNode)) {
+	n.mtx.Lock()
+	fn(n)
+	n.mtx.Unlock()
+}
+
+func (n *fakeEventNode) txCount() int {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return n.txs
+}
+
+func (n *fakeEventNode) subscribeCount() int {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return n.subscribes
+}
+
+func newEventClient(t *testing.T, node *fakeEventNode) *client.ChainClient {
+	server := httptest.NewServer(node)
+	t.Cleanup(server.Close)
+
+	cl, err := client.NewChainClient(&client.ChainClientConfig{
+		ChainID:               fakeChainID,
+		RPCAddr:               server.URL,
+		Timeout:               "10s",
+		OutputFormat:          "json",
+		Modules:               client.DefaultModuleBasics,
+		CustomMsgTypeRegistry: client.DefaultCustomMsgTypeRegistry,
+	})
+	require.NoError(t, err, "Failed to create chain client")
+	return cl
+}
+
+func TestSubscribeTxsHeaderError(t *testing.T) {
+	node := &fakeEventNode{time: time.Unix(1700000000, 0).UTC(), headerErrs: 1}
+	cl := newEventClient(t, node)
+
+	query := querier.Query{Client: cl, Options: &querier.QueryOptions{}}
+	txs, sub, err := querier.SubscribeTxs(t.Context(), &query, "")
+	require.NoError(t, err, "Failed to subscribe to txs")
+	defer sub.Close()
+
+	// The subscribe call does not wait for the answer of the node
+	require.Eventually(t, func() bool { return node.subscribeCount() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	encoder := cl.Codec.TxConfig.TxEncoder()
+	for i := 0; i < 2; i++ {
+		builder := cl.Codec.TxConfig.NewTxBuilder()
+		builder.SetMemo(fmt.Sprintf("tx-%d", i))
+		tx, err := encoder(builder.GetTx())
+		require.NoError(t, err, "Failed to encode tx")
+
+		node.publish(t, coretypes.ResultEvent{
+			Query: cmttypes.EventQueryTx.String(),
+			Data:  cmttypes.EventDataTx{TxResult: abci.TxResult{Height: 42, Index: uint32(i), Tx: tx}},
+		})
+	}
+
+	failed := <-txs
+	require.Error(t, failed.Err, "A tx without block header should carry the lookup error")
+	assert.Nil(t, failed.TxResponse, "A tx without block header should not be timestamped")
+
+	decoded := <-txs
+	require.NoError(t, decoded.Err, "The header lookup should be retried with the next tx")
+	assert.Equal(t, "tx-1", decoded.Tx.Body.Memo)
+	assert.Equal(t, int64(42), decoded.TxResponse.Height)
+	assert.Equal(t, node.time.Format(time.RFC3339), decoded.TxResponse.Timestamp)
+}
+
+func TestSubscribeTxsDecodeError(t *testing.T) {
+	node := &fakeEventNode{time: time.Unix(1700000000, 0).UTC()}
+	cl := newEventClient(t, node)
+
+	query := querier.Query{Client: cl, Options: &querier.QueryOptions{}}
+	txs, sub, err := querier.SubscribeTxs(t.Context(), &query, "")
+	require.NoError(t, err, "Failed to subscribe to txs")
+	defer sub.Close()
+
+	require.Eventually(t, func() bool { return node.subscribeCount() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	builder := cl.Codec.TxConfig.NewTxBuilder()
+	builder.SetMemo("valid")
+	valid, err := cl.Codec.TxConfig.TxEncoder()(builder.GetTx())
+	require.NoError(t, err, "Failed to encode tx")
+	garbage := cmttypes.Tx("not a tx")
+
+	for i, tx := range []cmttypes.Tx{garbage, valid} {
+		node.publish(t, coretypes.ResultEvent{
+			Query: cmttypes.EventQueryTx.String(),
+			Data:  cmttypes.EventDataTx{TxResult: abci.TxResult{Height: 42, Index: uint32(i), Tx: tx}},
+		})
+	}
+
+	failed := <-txs
+	require.Error(t, failed.Err, "A tx which cannot be decoded should be delivered with the decoding error")
+	assert.Contains(t, failed.Err.Error(), fmt.Sprintf("%X", garbage.Hash()))
+	assert.Contains(t, failed.Err.Error(), "height 42")
+	assert.Nil(t, failed.Tx)
+
+	decoded := <-txs
+	require.NoError(t, decoded.Err)
+	assert.Equal(t, "valid", decoded.Tx.Body.Memo)
+}
+
+func TestSubscriptionResubscribesOnce(t *testing.T) {
+	node := &fakeEventNode{cancellations: 5}
+	cl := newEventClient(t, node)
+
+	sub, err := cl.Subscribe(t.Context(), cmttypes.EventQueryNewBlock.String(), 0)
+	require.NoError(t, err, "Failed to subscribe")
+	defer sub.Close()
+
+	require.Eventually(t, func() bool { return node.subscribeCount() >= 2 }, 5*time.Second, 10*time.Millisecond,
+		"The cancelled subscription should be made again")
+
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, 2, node.subscribeCount(), "Errors reported together should lead to a single resubscription")
+}
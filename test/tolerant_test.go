@@ -0,0 +1,48 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/RiemaLabs/probe/client"
+	querier "github.com/RiemaLabs/probe/query"
+	abci "github.com/cometbft/cometbft/abci/types"
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxsEventResponseEvents(t *testing.T) {
+	cdc, err := client.MakeCodec(client.DefaultModuleBasics, client.DefaultCustomMsgTypeRegistry)
+	require.NoError(t, err, "Failed to make codec")
+
+	builder := cdc.TxConfig.NewTxBuilder()
+	builder.SetMemo("events")
+	tx, err := cdc.TxConfig.TxEncoder()(builder.GetTx())
+	require.NoError(t, err, "Failed to encode tx")
+
+	events := []abci.Event{{Type: "transfer", Attributes: []abci.EventAttribute{{Key: "amount", Value: "1ubtc"}}}}
+	resultTx := func(tx cmttypes.Tx) *coretypes.ResultTx {
+		return &coretypes.ResultTx{Hash: tx.Hash(), Height: 42, Tx: tx, TxResult: abci.ExecTxResult{Events: events}}
+	}
+	timestamp := time.Unix(1700000000, 0).UTC()
+
+	res, err := querier.BuildGetTxsEventResponse(timestamp, &coretypes.ResultTxSearch{Txs: []*coretypes.ResultTx{resultTx(tx)}, TotalCount: 1}, cdc.TxConfig.TxDecoder(), nil)
+	require.NoError(t, err)
+	assert.Empty(t, res.TxResponses[0].Events, "The default responses should keep their output")
+
+	garbage := cmttypes.Tx{0xff, 0xff, 0xff}
+	tolerant, diagnostics := querier.BuildTolerantTxsEventResponse(timestamp, &coretypes.ResultTxSearch{
+		Txs:        []*coretypes.ResultTx{resultTx(tx), resultTx(garbage)},
+		TotalCount: 2,
+	}, cdc, nil)
+	require.Len(t, tolerant.TxResponses, 2)
+	require.Len(t, diagnostics, 1, "Only the undecodable tx should be diagnosed")
+	assert.Equal(t, fmt.Sprintf("%X", garbage.Hash()), diagnostics[0].TxHash)
+	assert.Equal(t, "events", tolerant.Txs[0].Body.Memo)
+	for _, txResponse := range tolerant.TxResponses {
+		assert.Equal(t, events, txResponse.Events, "The tolerant responses should carry the tx events")
+	}
+}
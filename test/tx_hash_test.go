@@ -0,0 +1,48 @@
+package test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/RiemaLabs/probe/client"
+	querier "github.com/RiemaLabs/probe/query"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxByHashProof(t *testing.T) {
+	fake, cl := newFakeChain(t, 3, false)
+	query := querier.Query{Client: cl, Options: &querier.QueryOptions{}}
+	hash := hex.EncodeToString(fake.txs[1].Hash())
+
+	res, err := querier.TxByHashRPC(&query, hash, true)
+	require.NoError(t, err, "A valid inclusion proof should be accepted")
+	assert.Equal(t, "tx-1", res.Tx.Body.Memo)
+	assert.Equal(t, fake.height, res.TxResponse.Height)
+	assert.Equal(t, fake.time.Format("2006-01-02T15:04:05Z"), res.TxResponse.Timestamp)
+
+	fake.tamperProof = true
+	_, err = querier.TxByHashRPC(&query, hash, true)
+	assert.ErrorContains(t, err, "invalid tx proof", "A proof of other data should be rejected")
+
+	_, err = querier.TxByHashRPC(&query, hash, false)
+	assert.NoError(t, err, "The proof should only be checked when requested")
+}
+
+func TestTxsByHashBatch(t *testing.T) {
+	fake, cl := newFakeChain(t, 3, false)
+	query := querier.Query{Client: cl, Options: &querier.QueryOptions{}}
+
+	hashes := []string{hex.EncodeToString(fake.txs[2].Hash()), "0x" + hex.EncodeToString(fake.txs[0].Hash())}
+	res, err := querier.TxsByHashRPC(&query, hashes, true)
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	assert.Equal(t, "tx-2", res[0].Tx.Body.Memo, "The results should be in the order of the hashes")
+	assert.Equal(t, "tx-0", res[1].Tx.Body.Memo)
+
+	missing := hex.EncodeToString(make([]byte, 32))
+	res, err = querier.TxsByHashRPC(&query, append(hashes, missing), true)
+	assert.ErrorIs(t, err, client.ErrNotFound)
+	assert.ErrorContains(t, err, missing, "The failed hash should be reported")
+	assert.Nil(t, res, "A failed lookup should fail the batch")
+}
@@ -0,0 +1,246 @@
+package test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/RiemaLabs/probe/client"
+	querier "github.com/RiemaLabs/probe/query"
+	abci "github.com/cometbft/cometbft/abci/types"
+	"github.com/cometbft/cometbft/p2p"
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	rpctypes "github.com/cometbft/cometbft/rpc/jsonrpc/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	txTypes "github.com/cosmos/cosmos-sdk/types/tx"
+)
+
+const fakeChainID = "fake-chain"
+
+// fakeRPC serves the status, header, tx, tx_search, block and block_results methods of the
+// CometBFT RPC for a single block
+type fakeRPC struct {
+	height     int64
+	time       time.Time
+	txs        []cmttypes.Tx
+	noIndexing bool
+	// tamperProof alters the data proven by the tx inclusion proofs
+	tamperProof bool
+	headers     atomic.Int32
+	pages       atomic.Int32
+
+	mtx         sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (f *fakeRPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The RPC client opens its websocket when it starts, no event is ever sent on it
+	if r.URL.Path == "/websocket" {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}
+
+	var req rpctypes.RPCRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var params map[string]any
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var result any
+	switch req.Method {
+	case "status":
+		result = &coretypes.ResultStatus{NodeInfo: p2p.DefaultNodeInfo{Network: fakeChainID}}
+	case "header":
+		f.headers.Add(1)
+		result = &coretypes.ResultHeader{Header: &cmttypes.Header{ChainID: fakeChainID, Height: f.height, Time: f.time, DataHash: cmttypes.Txs(f.txs).Hash()}}
+	case "tx":
+		hash, _ := base64.StdEncoding.DecodeString(params["hash"].(string))
+		res := f.tx(hash)
+		if res == nil {
+			_ = json.NewEncoder(w).Encode(rpctypes.RPCInternalError(req.ID, fmt.Errorf("tx (%X) not found", hash)))
+			return
+		}
+		result = res
+	case "tx_search":
+		if f.noIndexing {
+			_ = json.NewEncoder(w).Encode(rpctypes.RPCInternalError(req.ID, errors.New("transaction indexing is disabled")))
+			return
+		}
+		f.pages.Add(1)
+		result = f.txSearch(intParam(params, "page"), intParam(params, "per_page"))
+	case "block":
+		result = &coretypes.ResultBlock{Block: &cmttypes.Block{
+			Header: cmttypes.Header{ChainID: fakeChainID, Height: f.height, Time: f.time},
+			Data:   cmttypes.Data{Txs: f.txs},
+		}}
+	case "block_results":
+		res := &coretypes.ResultBlockResults{Height: f.height}
+		for i := range f.txs {
+			res.TxsResults = append(res.TxsResults, &abci.ExecTxResult{GasUsed: int64(i)})
+		}
+		result = res
+	default:
+		http.Error(w, "unexpected method "+req.Method, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rpctypes.NewRPCSuccessResponse(req.ID, result))
+}
+
+// tx returns the tx of the block with the given hash and its inclusion proof, nil if there is none
+func (f *fakeRPC) tx(hash []byte) *coretypes.ResultTx {
+	for i, tx := range f.txs {
+		if !bytes.Equal(tx.Hash(), hash) {
+			continue
+		}
+
+		proof := cmttypes.Txs(f.txs).Proof(i)
+		if f.tamperProof {
+			proof.Data = append(cmttypes.Tx{}, proof.Data...)
+			proof.Data[0] ^= 0xff
+		}
+		return &coretypes.ResultTx{Hash: hash, Height: f.height, Index: uint32(i), Tx: tx, Proof: proof}
+	}
+	return nil
+}
+
+// txSearch returns a page of the txs of the block, after a random delay so that the pages
+// fetched concurrently complete out of order
+func (f *fakeRPC) txSearch(page, perPage int) *coretypes.ResultTxSearch {
+	f.mtx.Lock()
+	f.inFlight++
+	f.maxInFlight = max(f.maxInFlight, f.inFlight)
+	f.mtx.Unlock()
+
+	time.Sleep(time.Duration(rand.Intn(20)) * time.Millisecond)
+
+	f.mtx.Lock()
+	f.inFlight--
+	f.mtx.Unlock()
+
+	res := &coretypes.ResultTxSearch{TotalCount: len(f.txs)}
+	for i := (page - 1) * perPage; i < len(f.txs) && i < page*perPage; i++ {
+		res.Txs = append(res.Txs, &coretypes.ResultTx{
+			Hash:   f.txs[i].Hash(),
+			Height: f.height,
+			Index:  uint32(i),
+			Tx:     f.txs[i],
+		})
+	}
+	return res
+}
+
+func intParam(params map[string]any, key string) int {
+	s, _ := params[key].(string)
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// newFakeChain starts a fake RPC serving a block of total txs and returns a client connected to it
+func newFakeChain(t *testing.T, total int, noIndexing bool) (*fakeRPC, *client.ChainClient) {
+	fake := &fakeRPC{height: 42, time: time.Unix(1700000000, 0).UTC(), noIndexing: noIndexing}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	cl, err := client.NewChainClient(&client.ChainClientConfig{
+		ChainID:               fakeChainID,
+		RPCAddr:               server.URL,
+		Timeout:               "10s",
+		OutputFormat:          "json",
+		Modules:               client.DefaultModuleBasics,
+		CustomMsgTypeRegistry: client.DefaultCustomMsgTypeRegistry,
+	})
+	require.NoError(t, err, "Failed to create chain client")
+
+	encoder := cl.Codec.TxConfig.TxEncoder()
+	for i := 0; i < total; i++ {
+		builder := cl.Codec.TxConfig.NewTxBuilder()
+		builder.SetMemo(fmt.Sprintf("tx-%d", i))
+		tx, err := encoder(builder.GetTx())
+		require.NoError(t, err, "Failed to encode tx")
+		fake.txs = append(fake.txs, tx)
+	}
+
+	return fake, cl
+}
+
+// requireBlockTxs checks that res holds every tx of the fake block, in block order
+func requireBlockTxs(t *testing.T, fake *fakeRPC, res *txTypes.GetTxsEventResponse) {
+	total := len(fake.txs)
+	require.Len(t, res.Txs, total, "Every tx of the block should be returned")
+	require.Len(t, res.TxResponses, total, "Every tx response of the block should be returned")
+	assert.Equal(t, uint64(total), res.Total)
+	for i := range res.Txs {
+		assert.Equal(t, fmt.Sprintf("tx-%d", i), res.Txs[i].Body.Memo, "Txs should be in block order")
+		assert.Equal(t, fmt.Sprintf("%X", fake.txs[i].Hash()), res.TxResponses[i].TxHash, "Tx responses should be in block order")
+		assert.Equal(t, fake.height, res.TxResponses[i].Height)
+		assert.Equal(t, fake.time.Format(time.RFC3339), res.TxResponses[i].Timestamp)
+	}
+}
+
+func TestTxsAtHeightPagination(t *testing.T) {
+	for _, total := range []int{0, 1, 99, 100, 101, 150, 250, 301} {
+		t.Run(fmt.Sprintf("%d_txs", total), func(t *testing.T) {
+			fake, cl := newFakeChain(t, total, false)
+
+			query := querier.Query{Client: cl, Options: &querier.QueryOptions{}}
+			res, err := querier.TxsAtHeightRPC(&query, fake.height, cl.Codec)
+			require.NoError(t, err, "Failed to get txs at height")
+			requireBlockTxs(t, fake, res)
+
+			assert.Equal(t, int32(1), fake.headers.Load(), "The header should be fetched once per height")
+			assert.Equal(t, int32(max((total+99)/100, 1)), fake.pages.Load(), "Every page should be fetched once")
+			assert.LessOrEqual(t, fake.maxInFlight, querier.MaxConcurrentTxPages, "Page fetches should be bounded")
+		})
+	}
+}
+
+func TestTxsAtHeightWithoutTxIndexing(t *testing.T) {
+	fake, cl := newFakeChain(t, 150, true)
+
+	query := querier.Query{Client: cl, Options: &querier.QueryOptions{}}
+	res, err := querier.TxsAtHeightRPC(&query, fake.height, cl.Codec)
+	require.NoError(t, err, "Failed to get txs at height from the block")
+	requireBlockTxs(t, fake, res)
+
+	for i, txResponse := range res.TxResponses {
+		assert.Equal(t, int64(i), txResponse.GasUsed, "Tx results should be zipped with the txs")
+	}
+
+	tolerant, diagnostics, err := querier.TxsAtHeightRPCTolerant(&query, fake.height, cl.Codec)
+	require.NoError(t, err, "Failed to get txs at height from the block")
+	assert.Empty(t, diagnostics)
+	requireBlockTxs(t, fake, tolerant)
+}
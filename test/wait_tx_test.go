@@ -0,0 +1,98 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/RiemaLabs/probe/client"
+	querier "github.com/RiemaLabs/probe/query"
+	abci "github.com/cometbft/cometbft/abci/types"
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeTestTx(t *testing.T, cl *client.ChainClient, memo string) cmttypes.Tx {
+	builder := cl.Codec.TxConfig.NewTxBuilder()
+	builder.SetMemo(memo)
+	tx, err := cl.Codec.TxConfig.TxEncoder()(builder.GetTx())
+	require.NoError(t, err, "Failed to encode tx")
+	return tx
+}
+
+func TestWaitForTxPollsThroughTransientErrors(t *testing.T) {
+	node := &fakeEventNode{time: time.Unix(1700000000, 0).UTC()}
+	cl := newEventClient(t, node)
+	cl.Config.Retry = &client.RetryPolicy{InitialBackoff: "5ms", MaxBackoff: "5ms"}
+
+	tx := encodeTestTx(t, cl, "polled")
+	node.set(func(n *fakeEventNode) {
+		n.noWebsocket = true
+		n.txErrs = []error{errUnavailable, errUnavailable, fmt.Errorf("tx (%X) not found", tx.Hash())}
+		n.tx = &coretypes.ResultTx{Hash: tx.Hash(), Height: 42, Tx: tx}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := querier.Query{Client: cl, Options: &querier.QueryOptions{}}
+	res, err := querier.WaitForTxRPC(ctx, &query, fmt.Sprintf("%X", tx.Hash()))
+	require.NoError(t, err, "Transient lookup failures should not end the wait")
+	assert.Equal(t, int64(42), res.Height)
+	assert.Equal(t, 4, node.txCount(), "The tx should be looked up until it is found")
+}
+
+func TestWaitForTxFailsOnDeterministicErrors(t *testing.T) {
+	node := &fakeEventNode{time: time.Unix(1700000000, 0).UTC()}
+	cl := newEventClient(t, node)
+	cl.Config.Retry = &client.RetryPolicy{InitialBackoff: "5ms", MaxBackoff: "5ms"}
+	node.set(func(n *fakeEventNode) {
+		n.noWebsocket = true
+		n.txErrs = []error{errors.New("transaction indexing is disabled")}
+	})
+
+	query := querier.Query{Client: cl, Options: &querier.QueryOptions{}}
+	_, err := querier.WaitForTxRPC(context.Background(), &query, fmt.Sprintf("%X", make([]byte, 32)))
+	assert.ErrorIs(t, err, client.ErrTxIndexingDisabled, "Polling a node without tx indexing cannot succeed")
+}
+
+func TestWaitForTxSubscribedWithoutTxIndexing(t *testing.T) {
+	node := &fakeEventNode{time: time.Unix(1700000000, 0).UTC()}
+	cl := newEventClient(t, node)
+	node.set(func(n *fakeEventNode) {
+		n.txErrs = []error{errors.New("transaction indexing is disabled")}
+	})
+
+	tx := encodeTestTx(t, cl, "subscribed")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	type result struct {
+		res *sdk.TxResponse
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		query := querier.Query{Client: cl, Options: &querier.QueryOptions{}}
+		res, err := querier.WaitForTxRPC(ctx, &query, fmt.Sprintf("%X", tx.Hash()))
+		done <- result{res, err}
+	}()
+
+	require.Eventually(t, func() bool { return node.subscribeCount() == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return node.txCount() == 1 }, 5*time.Second, 10*time.Millisecond, "The tx should be looked up once subscribed")
+
+	node.publish(t, coretypes.ResultEvent{
+		Query: cmttypes.EventQueryTx.String(),
+		Data:  cmttypes.EventDataTx{TxResult: abci.TxResult{Height: 42, Tx: tx}},
+	})
+
+	r := <-done
+	require.NoError(t, r.err, "The tx should be delivered by the subscription")
+	assert.Equal(t, int64(42), r.res.Height)
+	assert.Equal(t, node.time.Format(time.RFC3339), r.res.Timestamp)
+}